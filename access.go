@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Файл со списком пользователей, добавленных командой /users
+const defaultAccessPath = "access.json"
+
+// Роли пользователей; у каждой следующей роли есть все права предыдущей
+type role int
+
+const (
+	roleNone role = iota
+	// Отправка файлов и получение отчетов, личные настройки вывода
+	roleTeacher
+	// Пороги отчетов, сводная панель, плановая рассылка
+	roleMethodologist
+	// Управление доступом
+	roleAdmin
+)
+
+var roleNames = map[role]string{
+	roleTeacher:       "teacher",
+	roleMethodologist: "methodologist",
+	roleAdmin:         "admin",
+}
+
+var roleLabels = map[role]string{
+	roleTeacher:       "преподаватель",
+	roleMethodologist: "методист",
+	roleAdmin:         "администратор",
+}
+
+func parseRole(name string) (role, bool) {
+	for r, n := range roleNames {
+		if n == name {
+			return r, true
+		}
+	}
+	return roleNone, false
+}
+
+func (r role) MarshalText() ([]byte, error) {
+	return []byte(roleNames[r]), nil
+}
+
+func (r *role) UnmarshalText(text []byte) error {
+	parsed, ok := parseRole(string(text))
+	if !ok {
+		return fmt.Errorf("неизвестная роль %q", text)
+	}
+	*r = parsed
+	return nil
+}
+
+// Минимальная роль для команд; команды, которых нет в списке, доступны всем
+// пользователям из списка доступа
+var commandRoles = map[string]role{
+	"settings":  roleMethodologist,
+	"schedule":  roleMethodologist,
+	"dashboard": roleMethodologist,
+	"users":     roleAdmin,
+	"audit":     roleAdmin,
+}
+
+// Команда, которая показывает кнопки с этим префиксом данных: нажатие
+// кнопки требует той же роли, что и команда. Пустая команда — кнопки,
+// которые бот показывает при обработке отправленного файла
+var callbackCommands = map[string]string{
+	"mode_":     "setmode",
+	"settings_": "settings",
+	"format_":   "format",
+	"sheets_":   "sheets",
+	"compare_":  "compare",
+	"detect_":   "",
+	"sheetsel_": "",
+}
+
+// Минимальная роль для команды
+func commandRole(command string) role {
+	return max(roleTeacher, commandRoles[command])
+}
+
+// Минимальная роль для кнопки по ее данным
+func callbackRole(data string) role {
+	required := roleTeacher
+	for prefix, command := range callbackCommands {
+		if strings.HasPrefix(data, prefix) {
+			required = max(required, commandRole(command))
+		}
+	}
+	return required
+}
+
+// Список доступа: ID пользователей или чатов (у групп ID отрицательные) и их
+// роли. Записи из .env задаются администратором бота и командой /users не
+// меняются, остальные хранятся в файле
+type accessList struct {
+	mu         sync.RWMutex
+	path       string
+	configured map[int64]role
+	added      map[int64]role
+}
+
+var access *accessList
+
+func accessPath() string {
+	if path := os.Getenv("access_path"); path != "" {
+		return path
+	}
+	return defaultAccessPath
+}
+
+// Загрузка списка доступа из .env (admin_ids, methodologist_ids, teacher_ids)
+// и из файла с добавленными пользователями
+func loadAccessList(path string) error {
+	a := &accessList{path: path, configured: make(map[int64]role), added: make(map[int64]role)}
+	for _, r := range []role{roleTeacher, roleMethodologist, roleAdmin} {
+		key := roleNames[r] + "_ids"
+		for _, field := range strings.FieldsFunc(os.Getenv(key), func(c rune) bool { return c == ',' || c == ' ' }) {
+			id, err := strconv.ParseInt(field, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%s: некорректный ID %q", key, field)
+			}
+			a.configured[id] = max(a.configured[id], r)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &a.added); err != nil {
+			return fmt.Errorf("файл %s поврежден: %w", path, err)
+		}
+	}
+	if a.empty() {
+		slog.Warn("Список доступа пуст: бот доступен всем, команды администратора отключены. Укажите admin_ids в .env")
+	}
+	access = a
+	return nil
+}
+
+func (a *accessList) empty() bool {
+	return len(a.configured) == 0 && len(a.added) == 0
+}
+
+// Список доступа не настроен: бот без настройки работает как раньше и
+// доступен всем, но только с правами методиста. Команды администратора
+// (/users, /audit с файлами и пользователями всех чатов) отключены
+func (a *accessList) open() bool {
+	if a == nil {
+		return true
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.empty()
+}
+
+// Роль для обновления: наибольшая из ролей пользователя и чата, так что
+// добавленная группа доступна всем ее участникам
+func (a *accessList) role(userID, chatID int64) role {
+	if a.open() {
+		return roleMethodologist
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	r := max(a.configured[userID], a.added[userID])
+	if chatID != 0 {
+		r = max(r, a.configured[chatID], a.added[chatID])
+	}
+	return r
+}
+
+func (a *accessList) set(id int64, r role) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.configured[id]; ok {
+		return fmt.Errorf("%d задан в .env, измените роль там", id)
+	}
+	if r == roleNone {
+		if _, ok := a.added[id]; !ok {
+			return fmt.Errorf("%d нет в списке доступа", id)
+		}
+		delete(a.added, id)
+	} else {
+		a.added[id] = r
+	}
+	data, err := json.MarshalIndent(a.added, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(a.path, data)
+}
+
+// Список доступа для /users: ID по возрастанию с ролями и источником
+func (a *accessList) describe() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	var lines []string
+	for _, entries := range []struct {
+		ids    map[int64]role
+		source string
+	}{{a.configured, " (.env)"}, {a.added, ""}} {
+		ids := make([]int64, 0, len(entries.ids))
+		for id := range entries.ids {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for _, id := range ids {
+			lines = append(lines, fmt.Sprintf("%d — %s%s", id, roleLabels[entries.ids[id]], entries.source))
+		}
+	}
+	if len(lines) == 0 {
+		return "Список доступа пуст, бот доступен всем"
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Проверка доступа до обработки обновления: посторонним не отвечаем ничем,
+// кроме их ID, и не скачиваем их файлы
+func authorizeUpdate(bot *tgbotapi.BotAPI, update tgbotapi.Update) (role, bool) {
+	var userID, chatID int64
+	if user := update.SentFrom(); user != nil {
+		userID = user.ID
+	}
+	if chat := update.FromChat(); chat != nil {
+		chatID = chat.ID
+	}
+	r := access.role(userID, chatID)
+
+	required := roleTeacher
+	switch {
+	case update.Message != nil && update.Message.IsCommand():
+		required = commandRole(update.Message.Command())
+	case update.CallbackQuery != nil:
+		required = callbackRole(update.CallbackQuery.Data)
+	}
+	if r >= required {
+		return r, true
+	}
+
+	text := "Недостаточно прав для этого действия"
+	switch {
+	case required == roleAdmin && access.open():
+		// Пока список пуст, первый же добавленный через /users закрыл бы бот для всех остальных
+		text = "Список доступа не настроен. Укажите ID администраторов в admin_ids в .env и перезапустите бота"
+	case r == roleNone:
+		text = fmt.Sprintf("Доступ к боту закрыт. Передайте администратору ваш ID: %d", userID)
+	}
+	if update.Message != nil && update.Message.Document != nil {
+		recordFileOutcome(auditEntry{
+			ChatID: chatID, UserID: userID, User: userLabel(update.SentFrom()),
+			File: update.Message.Document.FileName, Outcome: outcomeDenied,
+		}, time.Now())
+	}
+	switch {
+	case update.CallbackQuery != nil:
+		bot.Request(tgbotapi.NewCallback(update.CallbackQuery.ID, text))
+	case update.Message != nil:
+		bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, text))
+	}
+	return r, false
+}
+
+// Команда /users: без аргументов — список доступа, «/users add <ID> [роль]» —
+// добавление или смена роли, «/users remove <ID>» — удаление
+func handleUsersCommand(bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	args := strings.Fields(strings.ToLower(msg.CommandArguments()))
+	usage := "Добавить: /users add <ID> [teacher|methodologist|admin]\nУдалить: /users remove <ID>\n" +
+		"ID пользователя бот сообщает ему при попытке доступа; ID группы отрицательный"
+
+	if len(args) == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, "Список доступа:\n"+access.describe()+"\n\n"+usage))
+		return
+	}
+	if len(args) < 2 || len(args) > 3 || (args[0] != "add" && args[0] != "remove") {
+		bot.Send(tgbotapi.NewMessage(chatID, usage))
+		return
+	}
+	id, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || id == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, "Некорректный ID: "+args[1]))
+		return
+	}
+
+	r := roleNone
+	if args[0] == "add" {
+		r = roleTeacher
+		if len(args) == 3 {
+			var ok bool
+			if r, ok = parseRole(args[2]); !ok {
+				bot.Send(tgbotapi.NewMessage(chatID, "Неизвестная роль. Доступны: teacher, methodologist, admin"))
+				return
+			}
+		}
+	}
+	if msg.From != nil && id == msg.From.ID && r != roleAdmin {
+		bot.Send(tgbotapi.NewMessage(chatID, "Нельзя понизить или удалить самого себя"))
+		return
+	}
+	if err := access.set(id, r); err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "Не удалось изменить список доступа: "+err.Error()))
+		return
+	}
+	if r == roleNone {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("%d удален из списка доступа", id)))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("%d добавлен: %s", id, roleLabels[r])))
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Обновление от пользователя в его личном чате: команда или нажатие кнопки
+func commandUpdate(userID int64, text string) tgbotapi.Update {
+	return tgbotapi.Update{Message: &tgbotapi.Message{
+		From:     &tgbotapi.User{ID: userID},
+		Chat:     &tgbotapi.Chat{ID: userID},
+		Text:     text,
+		Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Length: len(text)}},
+	}}
+}
+
+func callbackUpdate(userID int64, data string) tgbotapi.Update {
+	return tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      "1",
+		From:    &tgbotapi.User{ID: userID},
+		Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: userID}},
+		Data:    data,
+	}}
+}
+
+func TestAuthorizeEmptyAccessList(t *testing.T) {
+	bot, _ := fakeTelegram(t)
+	saved := access
+	t.Cleanup(func() { access = saved })
+	access = &accessList{configured: map[int64]role{}, added: map[int64]role{}}
+
+	// Пока список не настроен, бот доступен всем, но не команды администратора
+	tests := []struct {
+		update tgbotapi.Update
+		want   bool
+	}{
+		{commandUpdate(42, "/audit"), false},
+		{commandUpdate(42, "/users"), false},
+		{commandUpdate(42, "/dashboard"), true},
+		{commandUpdate(42, "/schedule"), true},
+		{commandUpdate(42, "/start"), true},
+		{callbackUpdate(42, "settings_student_attendance"), true},
+		{callbackUpdate(42, "detect_mode_students"), true},
+	}
+	for _, tt := range tests {
+		if _, ok := authorizeUpdate(bot, tt.update); ok != tt.want {
+			t.Errorf("%s%s: доступ %v, ожидалось %v", messageText(tt.update), callbackData(tt.update), ok, tt.want)
+		}
+	}
+}
+
+func TestAuthorizeRoles(t *testing.T) {
+	bot, _ := fakeTelegram(t)
+	saved := access
+	t.Cleanup(func() { access = saved })
+	const teacher, methodologist, admin, stranger = 1, 2, 3, 4
+	access = &accessList{
+		configured: map[int64]role{admin: roleAdmin},
+		added:      map[int64]role{teacher: roleTeacher, methodologist: roleMethodologist},
+	}
+
+	tests := []struct {
+		update tgbotapi.Update
+		want   bool
+	}{
+		{commandUpdate(stranger, "/start"), false},
+		{callbackUpdate(stranger, "format_pdf"), false},
+		{callbackUpdate(stranger, "sheets_ask"), false},
+		{callbackUpdate(stranger, "detect_mode_students"), false},
+		{callbackUpdate(stranger, "mode_students"), false},
+		{callbackUpdate(teacher, "format_pdf"), true},
+		{callbackUpdate(teacher, "detect_mode_students"), true},
+		{callbackUpdate(teacher, "settings_student_attendance"), false},
+		{commandUpdate(teacher, "/dashboard"), false},
+		{callbackUpdate(methodologist, "settings_student_attendance"), true},
+		{commandUpdate(methodologist, "/audit"), false},
+		{commandUpdate(admin, "/audit"), true},
+	}
+	for _, tt := range tests {
+		if _, ok := authorizeUpdate(bot, tt.update); ok != tt.want {
+			t.Errorf("пользователь %d, %s%s: доступ %v, ожидалось %v", tt.update.SentFrom().ID, messageText(tt.update), callbackData(tt.update), ok, tt.want)
+		}
+	}
+}
+
+func messageText(u tgbotapi.Update) string {
+	if u.Message != nil {
+		return u.Message.Text
+	}
+	return ""
+}
+
+func callbackData(u tgbotapi.Update) string {
+	if u.CallbackQuery != nil {
+		return u.CallbackQuery.Data
+	}
+	return ""
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Файл журнала аудита по умолчанию и сколько записей показывает /audit
+const (
+	defaultAuditPath  = "audit.log"
+	auditDefaultLimit = 20
+	auditMaxLimit     = 200
+)
+
+// Итоги обработки файла в журнале аудита
+const (
+	outcomeOK         = "ok"
+	outcomeError      = "error"
+	outcomeDownload   = "download_failed"
+	outcomeUnknown    = "unknown_type"
+	outcomeAskType    = "ask_type"
+	outcomeAskSheets  = "ask_sheets"
+	outcomeDenied     = "denied"
+	outcomeNotAllowed = "rejected_format"
+	outcomeTooLarge   = "too_large"
+)
+
+var outcomeLabels = map[string]string{
+	outcomeOK:         "обработан",
+	outcomeError:      "ошибка",
+	outcomeDownload:   "не скачан",
+	outcomeUnknown:    "тип не определен",
+	outcomeAskType:    "уточняется тип",
+	outcomeAskSheets:  "выбираются листы",
+	outcomeDenied:     "нет доступа",
+	outcomeNotAllowed: "не Excel",
+	outcomeTooLarge:   "слишком большой",
+}
+
+// Запись журнала аудита: кто, где и какой файл отправил и чем закончилась
+// обработка. Категория — тип, определенный по заголовкам (пусто, если режим
+// выбран в чате), обработчик — тип, которым файл обработан
+type auditEntry struct {
+	Time      time.Time `json:"time"`
+	ChatID    int64     `json:"chat_id"`
+	UserID    int64     `json:"user_id,omitempty"`
+	User      string    `json:"user,omitempty"`
+	File      string    `json:"file"`
+	Category  string    `json:"category,omitempty"`
+	Processor string    `json:"processor,omitempty"`
+	Duration  int64     `json:"duration_ms"`
+	Outcome   string    `json:"outcome"`
+	Error     string    `json:"error,omitempty"`
+	// Ошибка обработки: ее текст попадает в Error, а вид — в метрики
+	err error
+}
+
+// Журнал аудита: JSON-строки, которые только дописываются в конец файла
+type auditLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+var audit *auditLog
+
+func auditPath() string {
+	if path := os.Getenv("audit_path"); path != "" {
+		return path
+	}
+	return defaultAuditPath
+}
+
+// Проверка, что в журнал можно писать, до запуска бота
+func openAuditLog(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	audit = &auditLog{path: path}
+	return f.Close()
+}
+
+func (a *auditLog) append(entry auditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Последние limit записей, подходящих под match, от старых к новым.
+// Поврежденные строки (например, недописанные при сбое) пропускаются
+func (a *auditLog) last(limit int, match func(auditEntry) bool) ([]auditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := os.Open(a.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []auditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry auditEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil || !match(entry) {
+			continue
+		}
+		entries = append(entries, entry)
+		if len(entries) > limit {
+			entries = entries[1:]
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// Имя пользователя для журналов: @username или имя из профиля
+func userLabel(user *tgbotapi.User) string {
+	if user == nil {
+		return ""
+	}
+	if user.UserName != "" {
+		return "@" + user.UserName
+	}
+	return strings.TrimSpace(user.FirstName + " " + user.LastName)
+}
+
+// Итог обработки файла: строка в журнале программы и запись аудита
+func recordFileOutcome(entry auditEntry, started time.Time) {
+	entry.Time = time.Now()
+	entry.Duration = entry.Time.Sub(started).Milliseconds()
+	if entry.err != nil {
+		entry.Error = entry.err.Error()
+	}
+	metrics.countFileOutcome(entry)
+	attrs := []any{
+		"chat_id", entry.ChatID, "user_id", entry.UserID, "user", entry.User,
+		"file", entry.File, "category", entry.Category, "processor", entry.Processor,
+		"duration", entry.Time.Sub(started), "outcome", entry.Outcome,
+	}
+	if entry.Error != "" {
+		attrs = append(attrs, "err", entry.Error)
+	}
+	switch entry.Outcome {
+	case outcomeOK:
+		slog.Info("Файл обработан", attrs...)
+	case outcomeAskType, outcomeAskSheets:
+		slog.Info("Файл ждет выбора пользователя", attrs...)
+	default:
+		slog.Warn("Файл не обработан", attrs...)
+	}
+	if audit == nil {
+		return
+	}
+	if err := audit.append(entry); err != nil {
+		slog.Error("Не удалось записать журнал аудита", "err", err)
+	}
+}
+
+// Команда /audit: последние записи журнала. Аргументы в любом порядке:
+// число — сколько записей показать, chat=<ID> и user=<ID> — фильтры
+func handleAuditCommand(bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	usage := "Формат: /audit [количество] [chat=<ID>] [user=<ID>]"
+	if audit == nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "Журнал аудита недоступен"))
+		return
+	}
+
+	limit := auditDefaultLimit
+	var filterChat, filterUser int64
+	for _, arg := range strings.Fields(strings.ToLower(msg.CommandArguments())) {
+		key, value, isFilter := strings.Cut(arg, "=")
+		if !isFilter {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n <= 0 {
+				bot.Send(tgbotapi.NewMessage(chatID, usage))
+				return
+			}
+			limit = min(n, auditMaxLimit)
+			continue
+		}
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || (key != "chat" && key != "user") {
+			bot.Send(tgbotapi.NewMessage(chatID, usage))
+			return
+		}
+		if key == "chat" {
+			filterChat = id
+		} else {
+			filterUser = id
+		}
+	}
+
+	entries, err := audit.last(limit, func(e auditEntry) bool {
+		return (filterChat == 0 || e.ChatID == filterChat) && (filterUser == 0 || e.UserID == filterUser)
+	})
+	if err != nil && !os.IsNotExist(err) {
+		slog.Error("Не удалось прочитать журнал аудита", "err", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "Не удалось прочитать журнал аудита"))
+		return
+	}
+	if len(entries) == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, "В журнале аудита нет записей"))
+		return
+	}
+
+	section := ReportSection{
+		Title:   fmt.Sprintf("Последние записи (%d):", len(entries)),
+		Columns: []string{"Время", "Чат", "Пользователь", "Файл", "Тип", "Итог", "Секунд"},
+		Style:   listNumbered,
+	}
+	for _, e := range entries {
+		user := e.User
+		if e.UserID != 0 {
+			user = strings.TrimSpace(fmt.Sprintf("%s %d", e.User, e.UserID))
+		}
+		kind := e.Processor
+		if kind == "" {
+			kind = e.Category
+		}
+		if p := processorByCallback(kind); p != nil {
+			kind = p.Name()
+		}
+		outcome := outcomeLabels[e.Outcome]
+		if outcome == "" {
+			outcome = e.Outcome
+		}
+		if e.Error != "" {
+			outcome += ": " + e.Error
+		}
+		cells := []string{
+			e.Time.Local().Format("02.01.2006 15:04:05"), strconv.FormatInt(e.ChatID, 10), user,
+			e.File, kind, outcome, strconv.FormatFloat(float64(e.Duration)/1000, 'f', 1, 64),
+		}
+		file := "«" + e.File + "»"
+		if kind != "" {
+			file += " (" + kind + ")"
+		}
+		section.Rows = append(section.Rows, ReportRow{
+			Cells: cells,
+			Text:  fmt.Sprintf("%s, чат %d, %s: %s — %s, %s с", cells[0], e.ChatID, user, file, outcome, cells[6]),
+		})
+	}
+	sendReport(bot, chatID, &Report{Name: "Журнал аудита", Title: "🗂 ЖУРНАЛ АУДИТА", Sections: []ReportSection{section}})
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Ограничения пакетной обработки: архив может оказаться «бомбой»
+// из тысяч файлов или гигабайт после распаковки
+const (
+	maxBatchFiles       = 20
+	maxArchiveEntrySize = 50 << 20
+	// Сколько ждать остальные файлы альбома после очередного
+	mediaGroupDelay = 2 * time.Second
+)
+
+// Файл пакета, уже скачанный или распакованный во временный файл
+type batchFile struct {
+	name string
+	path string
+	// Отправленный файл или архив, из которого он распакован
+	doc pendingFile
+}
+
+func isWorkbookName(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasSuffix(name, ".xlsx") || strings.HasSuffix(name, ".xls")
+}
+
+func isArchiveName(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".zip")
+}
+
+// Альбом из нескольких файлов: Telegram присылает каждый файл отдельным
+// сообщением с общим MediaGroupID, поэтому файлы собираются, пока не
+// пройдет mediaGroupDelay без новых
+type mediaGroup struct {
+	chatID int64
+	files  []pendingFile
+	timer  *time.Timer
+}
+
+var (
+	mediaGroupsMu sync.Mutex
+	mediaGroups   = make(map[string]*mediaGroup)
+	// Альбомы, которые собираются или обрабатываются; их ждут при остановке
+	mediaGroupsWG sync.WaitGroup
+)
+
+func collectMediaGroup(bot *tgbotapi.BotAPI, chatID int64, groupID string, file pendingFile) {
+	mediaGroupsMu.Lock()
+	defer mediaGroupsMu.Unlock()
+	g, ok := mediaGroups[groupID]
+	if !ok {
+		g = &mediaGroup{chatID: chatID}
+		// Альбом обрабатывается в очереди своего чата, как обычные обновления,
+		// чтобы не нарушать порядок внутри чата. Если пул уже остановлен,
+		// альбом обрабатывается сразу, а остановка ждет его через mediaGroupsWG
+		g.timer = time.AfterFunc(mediaGroupDelay, func() {
+			flush := func() { flushMediaGroup(bot, groupID) }
+			if !workers.run(chatID, flush) {
+				flush()
+			}
+		})
+		mediaGroups[groupID] = g
+		mediaGroupsWG.Add(1)
+	} else {
+		g.timer.Reset(mediaGroupDelay)
+	}
+	g.files = append(g.files, file)
+}
+
+// Обработка собранного альбома. Повторный вызов (таймер сработал еще раз,
+// пока альбом ждал в очереди) ничего не делает
+func flushMediaGroup(bot *tgbotapi.BotAPI, groupID string) {
+	mediaGroupsMu.Lock()
+	g, ok := mediaGroups[groupID]
+	delete(mediaGroups, groupID)
+	mediaGroupsMu.Unlock()
+	if !ok {
+		return
+	}
+	defer mediaGroupsWG.Done()
+	processBatch(bot, g.chatID, g.files)
+}
+
+// Обработка альбомов, которые еще собирались к остановке бота, и ожидание
+// уже запущенных. Вызывается после остановки пула
+func finishMediaGroups(bot *tgbotapi.BotAPI) {
+	mediaGroupsMu.Lock()
+	var waiting []string
+	for groupID, g := range mediaGroups {
+		// Сработавший таймер обработает альбом сам
+		if g.timer.Stop() {
+			waiting = append(waiting, groupID)
+		}
+	}
+	mediaGroupsMu.Unlock()
+	for _, groupID := range waiting {
+		flushMediaGroup(bot, groupID)
+	}
+	mediaGroupsWG.Wait()
+}
+
+// Обработка нескольких файлов (альбома или архивов) с общим отчетом:
+// тип каждого файла определяется автоматически, у каждого файла свои разделы
+func processBatch(bot *tgbotapi.BotAPI, chatID int64, docs []pendingFile) {
+	sentMsg, _ := bot.Send(tgbotapi.NewMessage(chatID, "⏳ Обрабатываю файлы..."))
+
+	var files []batchFile
+	var problems []string
+	defer func() {
+		for _, f := range files {
+			os.Remove(f.path)
+		}
+	}()
+	for i, doc := range docs {
+		started := time.Now()
+		localPath := fmt.Sprintf("temp_%d_%d_%d_%s", chatID, doc.messageID, i, path.Base(doc.filename))
+		if err := fetchFile(bot, doc.fileID, localPath); err != nil {
+			os.Remove(localPath)
+			entry := doc.auditEntry(chatID, outcomeDownload)
+			entry.err = err
+			problem := "ошибка при скачивании"
+			var tooLarge *fileTooLargeError
+			if errors.As(err, &tooLarge) {
+				entry.Outcome, problem = outcomeTooLarge, err.Error()
+			}
+			recordFileOutcome(entry, started)
+			problems = append(problems, fmt.Sprintf("Файл «%s»: %s", doc.filename, problem))
+			continue
+		}
+		if !isArchiveName(doc.filename) {
+			files = append(files, batchFile{name: doc.filename, path: localPath, doc: doc})
+			continue
+		}
+		extracted, err := extractArchive(localPath, strings.TrimSuffix(localPath, path.Ext(localPath)), maxBatchFiles-len(files))
+		os.Remove(localPath)
+		for _, f := range extracted {
+			f.doc = doc
+			f.doc.filename = doc.filename + "/" + f.name
+			files = append(files, f)
+		}
+		if err != nil {
+			entry := doc.auditEntry(chatID, outcomeError)
+			entry.err = err
+			recordFileOutcome(entry, started)
+			problems = append(problems, fmt.Sprintf("Архив «%s»: %v", doc.filename, err))
+		}
+	}
+
+	opts := chatProcessOptions(chatID)
+	var sources []string
+	var reports []*Report
+	for _, f := range files {
+		started := time.Now()
+		entry := f.doc.auditEntry(chatID, outcomeError)
+		processor, choices := pickFileType(determineFileType(f.path))
+		note := ""
+		if processor == nil && len(choices) > 0 {
+			// Спросить про каждый файл пакета нельзя, берется самый вероятный тип
+			processor = choices[0].processor
+			note = fmt.Sprintf(", определен с уверенностью %.0f%%", choices[0].confidence*100)
+		}
+		if processor == nil {
+			entry.Outcome = outcomeUnknown
+			recordFileOutcome(entry, started)
+			problems = append(problems, fmt.Sprintf("Файл «%s»: не удалось определить тип", f.name))
+			continue
+		}
+		entry.Category, entry.Processor = processor.CallbackID(), processor.CallbackID()
+		report, err := processor.Process(f.path, opts)
+		if err != nil {
+			entry.err = err
+			recordFileOutcome(entry, started)
+			problems = append(problems, fmt.Sprintf("Файл «%s»: %s", f.name, userMessage(err)))
+			continue
+		}
+		entry.Outcome = outcomeOK
+		recordFileOutcome(entry, started)
+		report.Name = processor.Name()
+		recordReport(chatID, processor.CallbackID(), report)
+		recordDashboardGrades(chatID, processor, f.path, opts)
+		sources = append(sources, fmt.Sprintf("Файл «%s» (%s%s)", f.name, processor.Name(), note))
+		reports = append(reports, report)
+	}
+
+	bot.Send(tgbotapi.NewDeleteMessage(chatID, sentMsg.MessageID))
+	if len(reports) == 0 && len(problems) == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, "Не найдено файлов Excel (.xlsx или .xls)"))
+		return
+	}
+	combined := mergeReports(sources, reports)
+	combined.Name = "Пакет файлов"
+	combined.Title = "📦 ОТЧЕТ ПО НЕСКОЛЬКИМ ФАЙЛАМ"
+	combined.Intro = fmt.Sprintf("Обработано файлов: %d", len(reports))
+	if len(problems) > 0 {
+		combined.Summary = strings.TrimSpace(combined.Summary + "\n\n⚠️ Не обработаны:\n" + strings.Join(problems, "\n"))
+	}
+	sendReport(bot, chatID, combined)
+}
+
+// Распаковка книг Excel из архива во временные файлы с префиксом prefix.
+// Каталоги, служебные файлы macOS и временные файлы Office пропускаются
+func extractArchive(archivePath, prefix string, limit int) ([]batchFile, error) {
+	r, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть архив")
+	}
+	defer r.Close()
+
+	var files []batchFile
+	for i, entry := range r.File {
+		name := zipEntryName(entry)
+		base := path.Base(name)
+		if entry.FileInfo().IsDir() || strings.HasPrefix(name, "__MACOSX/") ||
+			strings.HasPrefix(base, ".") || strings.HasPrefix(base, "~$") || !isWorkbookName(base) {
+			continue
+		}
+		if len(files) >= limit {
+			return files, fmt.Errorf("больше %d файлов, остальные пропущены", maxBatchFiles)
+		}
+		if entry.UncompressedSize64 > maxArchiveEntrySize {
+			return files, fmt.Errorf("файл «%s» больше %d МБ", base, maxArchiveEntrySize>>20)
+		}
+		localPath := fmt.Sprintf("%s_%d%s", prefix, i, path.Ext(base))
+		if err := extractEntry(entry, localPath); err != nil {
+			os.Remove(localPath)
+			return files, fmt.Errorf("не удалось распаковать «%s»", base)
+		}
+		files = append(files, batchFile{name: base, path: localPath})
+	}
+	return files, nil
+}
+
+func extractEntry(entry *zip.File, localPath string) error {
+	in, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	// Размер из заголовка архива может не совпадать с настоящим
+	n, err := io.Copy(out, io.LimitReader(in, maxArchiveEntrySize+1))
+	if err == nil && n > maxArchiveEntrySize {
+		return fmt.Errorf("файл больше %d МБ", maxArchiveEntrySize>>20)
+	}
+	return err
+}
+
+// Имя файла в архиве. Архивы, созданные в Windows без флага UTF-8, хранят
+// кириллические имена в кодировке CP866; такие имена не бывают корректным UTF-8
+func zipEntryName(entry *zip.File) string {
+	if !entry.NonUTF8 || utf8.ValidString(entry.Name) {
+		return entry.Name
+	}
+	var b strings.Builder
+	for i := 0; i < len(entry.Name); i++ {
+		b.WriteRune(decodeCP866(entry.Name[i]))
+	}
+	return b.String()
+}
+
+func decodeCP866(c byte) rune {
+	switch {
+	case c < 0x80:
+		return rune(c)
+	case c <= 0xAF:
+		return rune(0x0410 + int(c-0x80))
+	case c >= 0xE0 && c <= 0xEF:
+		return rune(0x0440 + int(c-0xE0))
+	case c == 0xF0:
+		return 'Ё'
+	case c == 0xF1:
+		return 'ё'
+	}
+	return '_'
+}
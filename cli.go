@@ -0,0 +1,225 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	godotenv "github.com/joho/godotenv"
+)
+
+// Коды завершения командной строки
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// Тип отчета для командной строки: CallbackID без префикса mode_
+func processorKey(p Processor) string {
+	return strings.TrimPrefix(p.CallbackID(), "mode_")
+}
+
+func processorByKey(key string) Processor {
+	return processorByCallback("mode_" + key)
+}
+
+// Повторяемый флаг вида ключ=значение
+type keyValueFlag map[string]string
+
+func (f keyValueFlag) String() string { return "" }
+
+func (f keyValueFlag) Set(value string) error {
+	key, v, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("ожидается ключ=значение")
+	}
+	f[strings.TrimSpace(key)] = strings.TrimSpace(v)
+	return nil
+}
+
+// Запуск без Telegram: «report process [флаги] файл...». Возвращает код завершения
+func runCLI(args []string, stdout, stderr io.Writer) int {
+	switch args[0] {
+	case "process":
+		return runProcessCommand(args[1:], stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		printCLIUsage(stdout)
+		return exitOK
+	}
+	fmt.Fprintf(stderr, "Неизвестная команда %q\n\n", args[0])
+	printCLIUsage(stderr)
+	return exitUsage
+}
+
+func printCLIUsage(w io.Writer) {
+	var types, formats []string
+	for _, p := range processors {
+		types = append(types, fmt.Sprintf("  %-20s %s", processorKey(p), p.Name()))
+	}
+	for _, f := range outputFormats {
+		formats = append(formats, f.key)
+	}
+	fmt.Fprintf(w, `Использование:
+  %[1]s                   запуск бота
+  %[1]s process [флаги] файл.xlsx...
+                          обработка файлов без Telegram
+
+Флаги process:
+  --type <тип>            тип отчета; без флага определяется по заголовкам
+  --format <формат>       %[2]s (по умолчанию text)
+  --output <файл>         куда записать отчет; по умолчанию в стандартный вывод
+  --sheet <лист>          обработать только этот лист, можно повторять
+  --threshold <порог>=<значение>
+                          порог отчета вместо значения по умолчанию, можно повторять
+  --column <поле>=<название>
+                          название колонки в файлах, как в /columns, можно повторять
+
+Типы отчетов:
+%[3]s
+`, filepath.Base(os.Args[0]), strings.Join(formats, ", "), strings.Join(types, "\n"))
+}
+
+func runProcessCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("process", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { printCLIUsage(stderr) }
+	typeKey := fs.String("type", "", "")
+	formatKey := fs.String("format", "text", "")
+	output := fs.String("output", "", "")
+	var sheets []string
+	fs.Func("sheet", "", func(v string) error {
+		sheets = append(sheets, v)
+		return nil
+	})
+	thresholds := keyValueFlag{}
+	fs.Var(thresholds, "threshold", "")
+	columns := keyValueFlag{}
+	fs.Var(columns, "column", "")
+
+	// Флаги можно указывать и после имен файлов
+	var files []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if err == flag.ErrHelp {
+				return exitOK
+			}
+			return exitUsage
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		files = append(files, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(stderr, "Не указан файл для обработки")
+		return exitUsage
+	}
+
+	// Пороги по умолчанию, как и у бота, можно задать в .env; файл не обязателен
+	godotenv.Load(".env")
+
+	var processor Processor
+	if *typeKey != "" {
+		if processor = processorByKey(*typeKey); processor == nil {
+			fmt.Fprintf(stderr, "Неизвестный тип отчета %q\n", *typeKey)
+			return exitUsage
+		}
+	}
+	format, ok := outputFormatByKey(*formatKey)
+	if !ok {
+		fmt.Fprintf(stderr, "Неизвестный формат %q\n", *formatKey)
+		return exitUsage
+	}
+	opts := processOptions{sheets: sheets}
+	if len(thresholds) > 0 {
+		opts.thresholds = make(map[string]float64)
+		for key, value := range thresholds {
+			t, ok := thresholdSettingByKey(key)
+			v, valid := t.parse(value)
+			if !ok || !valid {
+				fmt.Fprintf(stderr, "Некорректный порог %s=%s\n", key, value)
+				return exitUsage
+			}
+			opts.thresholds[key] = v
+		}
+	}
+	if len(columns) > 0 {
+		opts.columns = make(map[string][]string)
+		for key, name := range columns {
+			key = strings.ToLower(key)
+			if _, ok := columnFieldByKey(key); !ok || name == "" {
+				fmt.Fprintf(stderr, "Некорректная колонка %s=%s\n", key, name)
+				return exitUsage
+			}
+			opts.columns[key] = []string{name}
+		}
+	}
+
+	var sources []string
+	var reports []*Report
+	for _, file := range files {
+		if _, err := os.Stat(file); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		p := processor
+		if p == nil {
+			var choices []fileTypeCandidate
+			if p, choices = pickFileType(determineFileType(file)); p == nil {
+				fmt.Fprintf(stderr, "%s: не удалось определить тип отчета%s, укажите --type\n", file, describeCandidates(choices))
+				return exitError
+			}
+		}
+		report, err := p.Process(file, opts)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %s\n", file, userMessage(err))
+			return exitError
+		}
+		report.Name = p.Name()
+		sources = append(sources, fmt.Sprintf("Файл «%s» (%s)", filepath.Base(file), p.Name()))
+		reports = append(reports, report)
+	}
+	report := reports[0]
+	if len(reports) > 1 {
+		report = mergeReports(sources, reports)
+		report.Name = "Пакет файлов"
+		report.Title = "📦 ОТЧЕТ ПО НЕСКОЛЬКИМ ФАЙЛАМ"
+	}
+
+	var data []byte
+	if format.render == nil {
+		data = []byte(renderText(report) + "\n")
+	} else {
+		var err error
+		if data, err = format.render(report); err != nil {
+			fmt.Fprintf(stderr, "Не удалось сформировать отчет %s: %v\n", format.key, err)
+			return exitError
+		}
+	}
+	if *output == "" {
+		stdout.Write(data)
+		return exitOK
+	}
+	if err := os.WriteFile(*output, data, 0o644); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	return exitOK
+}
+
+// Возможные типы для сообщения об ошибке: « (похоже на: students 60%, ...)»
+func describeCandidates(choices []fileTypeCandidate) string {
+	if len(choices) == 0 {
+		return ""
+	}
+	var names []string
+	for _, c := range choices {
+		names = append(names, fmt.Sprintf("%s %.0f%%", processorKey(c.processor), c.confidence*100))
+	}
+	return " (похоже на: " + strings.Join(names, ", ") + ")"
+}
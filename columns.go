@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Поле отчета и названия колонки, под которыми оно встречается в выгрузках
+type columnField struct {
+	key     string
+	title   string
+	aliases []string
+}
+
+// Словарь колонок, общий для всех обработчиков. Названия сравниваются после
+// normalizeColumnName, поэтому регистр, лишние пробелы, точки и «ё» не важны
+var columnFields = []columnField{
+	{key: "fio", title: "ФИО", aliases: []string{"фио", "fio", "фамилия имя отчество", "студент", "обучающийся", "student", "full name"}},
+	{key: "teacher", title: "ФИО преподавателя", aliases: []string{"фио преподавателя", "преподаватель", "педагог", "teacher", "teacher name"}},
+	{key: "group", title: "Группа", aliases: []string{"группа", "group"}},
+	{key: "pair", title: "Пара", aliases: []string{"пара", "номер пары", "pair", "lesson number"}},
+	{key: "time", title: "Время", aliases: []string{"время", "time"}},
+	{key: "topic", title: "Тема урока", aliases: []string{"тема урока", "тема занятия", "тема", "lesson topic", "topic"}},
+	{key: "homework", title: "Домашняя работа", aliases: []string{"homework", "домашняя работа", "домашнее задание", "дз"}},
+	{key: "classwork", title: "Классная работа", aliases: []string{"classwork", "classroom", "классная работа", "работа на уроке"}},
+	{key: "attendance", title: "Посещаемость", aliases: []string{"средняя посещаемость", "посещаемость", "посещаем", "attendance", "average attendance"}},
+	{key: "checked", title: "Проверено", aliases: []string{"проверено", "проверенные", "checked"}},
+	{key: "received", title: "Получено", aliases: []string{"получено", "полученные", "received"}},
+	{key: "homework_percent", title: "Процент выполнения ДЗ", aliases: []string{"percentage homework", "homework percentage", "процент выполнения", "% выполнения", "процент дз"}},
+}
+
+func columnFieldByKey(key string) (columnField, bool) {
+	for _, f := range columnFields {
+		if f.key == key {
+			return f, true
+		}
+	}
+	return columnField{}, false
+}
+
+// Качество совпадения названия колонки с полем; чем больше, тем надежнее
+const (
+	matchNone = iota
+	// Отличается на одну-две буквы (опечатка)
+	matchFuzzy
+	// Название начинается с синонима или содержит его отдельным словом
+	matchPartial
+	matchExact
+	// Название, указанное пользователем командой /columns
+	matchOverride
+)
+
+// Приведение названия к виду для сравнения: «Ф.И.О.  Студента» → «фио студента»
+func normalizeColumnName(name string) string {
+	name = strings.ToLower(name)
+	name = strings.ReplaceAll(name, "ё", "е")
+	name = strings.ReplaceAll(name, ".", "")
+	name = strings.NewReplacer("_", " ", "-", " ", ":", " ").Replace(name)
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// Насколько название колонки подходит полю с учетом названий, которые
+// пользователь указал для этого поля в чате
+func matchColumnName(label string, field columnField, overrides []string) int {
+	label = normalizeColumnName(label)
+	if label == "" {
+		return matchNone
+	}
+	for _, o := range overrides {
+		if normalizeColumnName(o) == label {
+			return matchOverride
+		}
+	}
+	best := matchNone
+	for _, alias := range field.aliases {
+		alias = normalizeColumnName(alias)
+		switch {
+		case label == alias:
+			return matchExact
+		case hasWordPrefix(label, alias):
+			best = max(best, matchPartial)
+		case isTypo(label, alias):
+			best = max(best, matchFuzzy)
+		}
+	}
+	return best
+}
+
+// Синоним стоит в начале одного из слов названия: «посещаем» в «средняя посещаемость»
+func hasWordPrefix(label, alias string) bool {
+	for i := 0; i+len(alias) <= len(label); {
+		j := strings.Index(label[i:], alias)
+		if j < 0 {
+			return false
+		}
+		if i+j == 0 || label[i+j-1] == ' ' {
+			return true
+		}
+		i += j + 1
+	}
+	return false
+}
+
+// Опечатка: для коротких названий совпадения не ищутся, длинным допускается
+// одна-две ошибки
+func isTypo(label, alias string) bool {
+	n := len([]rune(alias))
+	switch {
+	case n >= 12:
+		return editDistance(label, alias) <= 2
+	case n >= 6:
+		return editDistance(label, alias) <= 1
+	}
+	return false
+}
+
+// Расстояние Левенштейна по символам
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func setChatColumnAlias(chatID int64, key, name string) error {
+	return updateChatSettings(chatID, func(s *ChatSettings) {
+		if s.Columns == nil {
+			s.Columns = make(map[string][]string)
+		}
+		if !slices.Contains(s.Columns[key], name) {
+			s.Columns[key] = append(s.Columns[key], name)
+		}
+	})
+}
+
+func resetChatColumnAliases(chatID int64) error {
+	return updateChatSettings(chatID, func(s *ChatSettings) {
+		s.Columns = nil
+	})
+}
+
+// Команда /columns: без аргументов — список полей и названий, указанных
+// в чате; «/columns <поле> = <название>» добавляет название колонки для поля,
+// «/columns reset» сбрасывает все указанные названия
+func handleColumnsCommand(bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	args := strings.TrimSpace(msg.CommandArguments())
+
+	if strings.EqualFold(args, "reset") {
+		if err := resetChatColumnAliases(chatID); err != nil {
+			slog.Error("Не удалось сохранить настройки чата", "chat_id", chatID, "err", err)
+		}
+		bot.Send(tgbotapi.NewMessage(chatID, "Названия колонок сброшены, используется только встроенный словарь"))
+		return
+	}
+
+	if args == "" {
+		overrides := getChatSettings(chatID).Columns
+		var b strings.Builder
+		b.WriteString("Поля отчетов и ваши названия колонок:\n\n")
+		for _, f := range columnFields {
+			b.WriteString(fmt.Sprintf("%s — %s", f.key, f.title))
+			if names := overrides[f.key]; len(names) > 0 {
+				b.WriteString(": " + strings.Join(names, ", "))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\nЧтобы указать колонку: /columns <поле> = <название колонки>\nНапример: /columns fio = Обучающийся\nСбросить: /columns reset")
+		bot.Send(tgbotapi.NewMessage(chatID, b.String()))
+		return
+	}
+
+	key, name, ok := strings.Cut(args, "=")
+	key, name = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(name)
+	field, known := columnFieldByKey(key)
+	if !ok || name == "" || !known {
+		bot.Send(tgbotapi.NewMessage(chatID, "Формат: /columns <поле> = <название колонки>\nСписок полей: /columns"))
+		return
+	}
+	if err := setChatColumnAlias(chatID, field.key, name); err != nil {
+		slog.Error("Не удалось сохранить настройки чата", "chat_id", chatID, "err", err)
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Колонка «%s» будет считаться полем «%s»", name, field.title)))
+}
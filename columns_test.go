@@ -0,0 +1,89 @@
+package main
+
+import "testing"
+
+func TestMatchColumnName(t *testing.T) {
+	tests := []struct {
+		label     string
+		field     string
+		overrides []string
+		want      int
+	}{
+		{"ФИО", "fio", nil, matchExact},
+		{"FIO", "fio", nil, matchExact},
+		{" Ф.И.О. ", "fio", nil, matchExact},
+		{"ФИО преподавателя", "fio", nil, matchPartial},
+		{"ФИО преподавателя", "teacher", nil, matchExact},
+		{"Преподователь", "teacher", nil, matchFuzzy},
+		{"Средняя посещаемость", "attendance", nil, matchExact},
+		{"Посещаемость, %", "attendance", nil, matchPartial},
+		// В словаре есть начало слова «посещаем», поэтому опечатка в конце не мешает
+		{"Посещаемсть", "attendance", nil, matchPartial},
+		{"Класная работа", "classwork", nil, matchFuzzy},
+		{"Percentage Homework", "homework_percent", nil, matchExact},
+		{"Percentage Homework", "homework", nil, matchPartial},
+		{"Classroom", "classwork", nil, matchExact},
+		{"Тема урока", "topic", nil, matchExact},
+		{"Получено", "checked", nil, matchNone},
+		{"Проверено", "checked", nil, matchExact},
+		{"Кол-во пар", "pair", nil, matchNone},
+		{"Date", "time", nil, matchNone},
+		{"", "fio", nil, matchNone},
+		{"Слушатель", "fio", nil, matchNone},
+		{"Слушатель", "fio", []string{" слушатель"}, matchOverride},
+		// Название из /columns важнее словаря
+		{"Группа", "fio", []string{"группа"}, matchOverride},
+	}
+	for _, tt := range tests {
+		field, ok := columnFieldByKey(tt.field)
+		if !ok {
+			t.Fatalf("нет поля %q", tt.field)
+		}
+		if got := matchColumnName(tt.label, field, tt.overrides); got != tt.want {
+			t.Errorf("matchColumnName(%q, %s, %q) = %d, ожидалось %d", tt.label, tt.field, tt.overrides, got, tt.want)
+		}
+	}
+}
+
+func TestHasWordPrefix(t *testing.T) {
+	tests := []struct {
+		label, alias string
+		want         bool
+	}{
+		{"фио преподавателя", "фио", true},
+		{"преподаватель фио", "фио", true},
+		{"месяц / проверено", "проверено", true},
+		{"тематика", "тема", true},
+		{"дфио", "фио", false},
+		// Первое вхождение внутри слова, второе — с начала слова
+		{"дфио фио", "фио", true},
+		{"фи", "фио", false},
+		{"", "фио", false},
+	}
+	for _, tt := range tests {
+		if got := hasWordPrefix(tt.label, tt.alias); got != tt.want {
+			t.Errorf("hasWordPrefix(%q, %q) = %v, ожидалось %v", tt.label, tt.alias, got, tt.want)
+		}
+	}
+}
+
+func TestIsTypo(t *testing.T) {
+	tests := []struct {
+		label, alias string
+		want         bool
+	}{
+		// От 12 символов допускаются две ошибки, от 6 — одна, короче — ни одной
+		{"преподователь", "преподаватель", true},
+		{"преподоватиль", "преподаватель", true},
+		{"прпадоватиль", "преподаватель", false},
+		{"грумпа", "группа", true},
+		{"грумпы", "группа", false},
+		{"пора", "пара", false},
+		{"получено", "проверено", false},
+	}
+	for _, tt := range tests {
+		if got := isTypo(tt.label, tt.alias); got != tt.want {
+			t.Errorf("isTypo(%q, %q) = %v, ожидалось %v", tt.label, tt.alias, got, tt.want)
+		}
+	}
+}
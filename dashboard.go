@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Отчеты, из которых собирается сводная панель. Значения берутся из ячеек
+// строк отчетов, поэтому порядок колонок должен совпадать с обработчиками:
+// посещаемость и проверка ДЗ — [ФИО, %], студенты — [ФИО, вид работы, оценка],
+// сданные ДЗ — [ФИО, %]
+const (
+	dashboardAttendance = "mode_attendance"
+	dashboardChecked    = "mode_checked_homework"
+	dashboardStudents   = "mode_students"
+	dashboardSubmitted  = "mode_submitted_homework"
+	// Оценки студентов по обеим работам, сохраняются в истории отдельно от отчета
+	dashboardStudentGrades = "dashboard_student_grades"
+)
+
+// Отмеченный человек из одного отчета: имя как в файле и значения по видам
+type dashboardPerson struct {
+	name   string
+	values map[string]string
+}
+
+// Люди из последней загрузки отчета, по ключу имени. Для отчета по
+// студентам значение кладется под видом работы, для остальных — под kind
+func dashboardPeople(chatID int64, kind string) (map[string]*dashboardPerson, *historyEntry) {
+	historyKind := kind
+	if kind == dashboardStudents {
+		historyKind = dashboardStudentGrades
+	}
+	entries := history.last(chatID, historyKind, 1)
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	people := make(map[string]*dashboardPerson)
+	for _, s := range entries[0].Report.Sections {
+		for _, row := range s.Rows {
+			if len(row.Cells) < 2 {
+				continue
+			}
+			key := personKey(row.Cells[0])
+			if key == "" {
+				continue
+			}
+			p, ok := people[key]
+			if !ok {
+				p = &dashboardPerson{name: strings.TrimSpace(row.Cells[0]), values: make(map[string]string)}
+				people[key] = p
+			}
+			if kind == dashboardStudents && len(row.Cells) >= 3 {
+				p.values[row.Cells[1]] = row.Cells[2]
+			} else {
+				p.values[kind] = strings.TrimSuffix(row.Cells[len(row.Cells)-1], "%")
+			}
+		}
+	}
+	return people, &entries[0]
+}
+
+// Оценки студентов для сводной панели. В отчете по студентам студент с
+// низкими оценками за обе работы указан один раз, а панели нужны обе,
+// поэтому файл обрабатывается еще раз с allGrades и сохраняется отдельно
+func recordDashboardGrades(chatID int64, processor Processor, filepath string, opts processOptions) {
+	if history == nil || processor.CallbackID() != dashboardStudents {
+		return
+	}
+	opts.allGrades, opts.progress = true, nil
+	report, err := processor.Process(filepath, opts)
+	if err != nil {
+		slog.Warn("Не удалось подготовить оценки для сводной панели", "chat_id", chatID, "err", err)
+		return
+	}
+	recordReport(chatID, dashboardStudentGrades, report)
+}
+
+// Ключ для сопоставления ФИО из разных выгрузок: регистр, «ё», лишние
+// пробелы и точки не важны, а «Иванов Иван Иванович» и «Иванов И. И.»
+// сводятся к фамилии с инициалами
+func personKey(name string) string {
+	words := strings.Fields(normalizeColumnName(strings.ReplaceAll(name, ".", " ")))
+	if len(words) == 0 {
+		return ""
+	}
+	key := words[0]
+	for _, w := range words[1:] {
+		r, _ := utf8.DecodeRuneInString(w)
+		key += " " + string(r)
+	}
+	return key
+}
+
+// Сводная панель по последним загрузкам: преподаватели, у которых низкие и
+// посещаемость, и проверка ДЗ; студенты с низкими оценками и за классную,
+// и за домашнюю работу; студенты с низкими оценками и невыполненными ДЗ
+func buildDashboard(chatID int64) *Report {
+	report := &Report{Name: "Сводная панель", Title: "🏫 СВОДНАЯ ПАНЕЛЬ"}
+
+	var sources, missing []string
+	load := func(kind string) map[string]*dashboardPerson {
+		people, entry := dashboardPeople(chatID, kind)
+		name := kind
+		if p := processorByCallback(kind); p != nil {
+			name = p.Name()
+		}
+		if entry == nil {
+			missing = append(missing, name)
+		} else {
+			sources = append(sources, fmt.Sprintf("%s (%s)", name, entry.Time.Format("02.01.2006")))
+		}
+		return people
+	}
+	attendance := load(dashboardAttendance)
+	checked := load(dashboardChecked)
+	students := load(dashboardStudents)
+	submitted := load(dashboardSubmitted)
+	if len(sources) == 0 {
+		return messageReport("Для сводной панели загрузите отчеты по посещаемости преподавателей, проверенным ДЗ, студентам или сданным ДЗ")
+	}
+	report.Intro = "Источники: " + strings.Join(sources, ", ")
+	if len(missing) > 0 {
+		report.Intro += "\nНет загрузок: " + strings.Join(missing, ", ")
+	}
+
+	teachers := ReportSection{
+		Title:   "Преподаватели с низкой посещаемостью и проверкой ДЗ:",
+		Columns: []string{"ФИО преподавателя", "Посещаемость, %", "Проверено, %"},
+		Style:   listNumbered,
+	}
+	for _, key := range sortedPeople(attendance) {
+		a := attendance[key]
+		if c, ok := checked[key]; ok {
+			att, chk := a.values[dashboardAttendance], c.values[dashboardChecked]
+			teachers.Rows = append(teachers.Rows, ReportRow{
+				Cells: []string{a.name, att, chk},
+				Text:  fmt.Sprintf("%s (посещаемость %s%%, проверено %s%%)", a.name, att, chk),
+			})
+		}
+	}
+
+	bothWork := ReportSection{
+		Title:   "Студенты с низкими оценками за классную и домашнюю работу:",
+		Columns: []string{"ФИО", "Домашняя", "Классная"},
+		Style:   listNumbered,
+	}
+	gradesAndHomework := ReportSection{
+		Title:   "Студенты с низкими оценками и невыполненными ДЗ:",
+		Columns: []string{"ФИО", "Домашняя", "Классная", "Выполнено ДЗ, %"},
+		Style:   listNumbered,
+	}
+	for _, key := range sortedPeople(students) {
+		s := students[key]
+		hw, cw := s.values["домашняя"], s.values["классная"]
+		if hw != "" && cw != "" {
+			bothWork.Rows = append(bothWork.Rows, ReportRow{
+				Cells: []string{s.name, hw, cw},
+				Text:  fmt.Sprintf("%s (домашняя: %s, классная: %s)", s.name, hw, cw),
+			})
+		}
+		if sub, ok := submitted[key]; ok {
+			percent := sub.values[dashboardSubmitted]
+			var grades []string
+			if hw != "" {
+				grades = append(grades, "домашняя: "+hw)
+			}
+			if cw != "" {
+				grades = append(grades, "классная: "+cw)
+			}
+			gradesAndHomework.Rows = append(gradesAndHomework.Rows, ReportRow{
+				Cells: []string{s.name, hw, cw, percent},
+				Text:  fmt.Sprintf("%s (%s; выполнено ДЗ: %s%%)", s.name, strings.Join(grades, ", "), percent),
+			})
+		}
+	}
+
+	for _, s := range []ReportSection{teachers, bothWork, gradesAndHomework} {
+		if len(s.Rows) > 0 {
+			report.Sections = append(report.Sections, s)
+		}
+	}
+	report.Summary = fmt.Sprintf(
+		"Преподавателей с низкой посещаемостью: %d, с низкой проверкой ДЗ: %d\nСтудентов с низкими оценками: %d, с невыполненными ДЗ: %d",
+		len(attendance), len(checked), len(students), len(submitted))
+	return report
+}
+
+// Ключи людей по алфавиту, чтобы порядок в панели не менялся от запуска к запуску
+func sortedPeople(people map[string]*dashboardPerson) []string {
+	keys := make([]string, 0, len(people))
+	for key := range people {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Команда /dashboard: сводная панель по последним загруженным отчетам
+func handleDashboardCommand(bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
+	if history == nil {
+		bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "История отчетов недоступна"))
+		return
+	}
+	sendReport(bot, msg.Chat.ID, buildDashboard(msg.Chat.ID))
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Параметры автоопределения типа файла
+const (
+	// Сколько первых строк каждого листа может оказаться заголовком
+	detectHeaderRows = 6
+	// Ниже этой уверенности тип файла считается неопознанным
+	detectMinConfidence = 0.5
+	// Если два лучших варианта ближе этой разницы, тип выбирает пользователь
+	detectAmbiguityGap = 0.15
+	// Сколько вариантов предлагать на выбор
+	detectMaxChoices = 3
+)
+
+// Признак типа отчета: одно из слов в ячейке заголовка. Отрицательный вес
+// означает, что признак говорит против этого типа
+type headerSignal struct {
+	words  []string
+	weight float64
+}
+
+func headerWords(weight float64, words ...string) headerSignal {
+	return headerSignal{words: words, weight: weight}
+}
+
+// Уверенность от 0 до 1: доля найденных положительных признаков за вычетом
+// найденных отрицательных
+func scoreHeader(signals []headerSignal, header []string) float64 {
+	var total, matched float64
+	for _, s := range signals {
+		if s.weight > 0 {
+			total += s.weight
+		}
+		if headerHasAny(header, s.words) {
+			matched += s.weight
+		}
+	}
+	if total == 0 || matched <= 0 {
+		return 0
+	}
+	return min(matched/total, 1)
+}
+
+func headerHasAny(header []string, words []string) bool {
+	for _, cell := range header {
+		for _, w := range words {
+			if strings.Contains(cell, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Вариант типа файла с уверенностью
+type fileTypeCandidate struct {
+	processor  Processor
+	confidence float64
+}
+
+// Определение типа файла по содержимому. Проверяются все листы и несколько
+// первых строк каждого, а также пары соседних строк для двухуровневых
+// заголовков. Возвращает варианты по убыванию уверенности
+func determineFileType(filepath string) []fileTypeCandidate {
+	wb, err := openWorkbook(filepath)
+	if err != nil {
+		return nil
+	}
+	defer wb.Close()
+
+	var headers [][]string
+	for _, sh := range wb.sheets {
+		reader, err := sh.openHead()
+		if err != nil {
+			continue
+		}
+		rows := reader.head()
+		reader.close()
+		limit := min(len(rows), detectHeaderRows)
+		for i := 0; i < limit; i++ {
+			headers = append(headers, normalizeHeader(rows[i]))
+			if i+1 < limit {
+				headers = append(headers, normalizeHeader(append(append([]string{}, rows[i]...), rows[i+1]...)))
+			}
+		}
+	}
+
+	var candidates []fileTypeCandidate
+	for _, p := range processors {
+		best := 0.0
+		for _, header := range headers {
+			best = max(best, p.Detect(header))
+		}
+		if best > 0 {
+			candidates = append(candidates, fileTypeCandidate{processor: p, confidence: best})
+		}
+	}
+	// Стабильная сортировка сохраняет порядок регистрации при равной уверенности
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].confidence > candidates[j].confidence
+	})
+	return candidates
+}
+
+func normalizeHeader(row []string) []string {
+	header := make([]string, 0, len(row))
+	for _, cell := range row {
+		if cell = strings.ToLower(strings.TrimSpace(cell)); cell != "" {
+			header = append(header, cell)
+		}
+	}
+	return header
+}
+
+// Выбор обработчика по результатам определения: единственный уверенный
+// вариант или список близких вариантов, из которых выбирает пользователь
+func pickFileType(candidates []fileTypeCandidate) (Processor, []fileTypeCandidate) {
+	var confident []fileTypeCandidate
+	for _, c := range candidates {
+		if c.confidence >= detectMinConfidence {
+			confident = append(confident, c)
+		}
+	}
+	if len(confident) == 0 {
+		return nil, nil
+	}
+	if len(confident) == 1 || confident[0].confidence-confident[1].confidence >= detectAmbiguityGap {
+		return confident[0].processor, nil
+	}
+	var choices []fileTypeCandidate
+	for _, c := range confident {
+		if confident[0].confidence-c.confidence < detectAmbiguityGap && len(choices) < detectMaxChoices {
+			choices = append(choices, c)
+		}
+	}
+	return nil, choices
+}
+
+// Файл, ожидающий выбора пользователя (типа отчета или листов). Telegram
+// хранит файл, поэтому после выбора он скачивается повторно по FileID
+type pendingFile struct {
+	messageID int
+	fileID    string
+	filename  string
+	// Уже выбранные обработчик и листы
+	processor Processor
+	sheets    []string
+	// Кто отправил файл, для журнала аудита
+	userID int64
+	user   string
+}
+
+func (f pendingFile) auditEntry(chatID int64, outcome string) auditEntry {
+	return auditEntry{ChatID: chatID, UserID: f.userID, User: f.user, File: f.filename, Outcome: outcome}
+}
+
+// Ожидающий выбор привязан к сообщению с вопросом: в чате может быть
+// несколько файлов сразу, и кнопка должна относиться к своему файлу
+type pendingKey struct {
+	chatID    int64
+	messageID int
+}
+
+var (
+	pendingFilesMu sync.Mutex
+	pendingFiles   = make(map[pendingKey]pendingFile)
+)
+
+// Вопрос пользователю, какой это отчет, с кнопками по вариантам
+func askFileType(bot *tgbotapi.BotAPI, chatID int64, file pendingFile, candidates []fileTypeCandidate) {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, c := range candidates {
+		label := fmt.Sprintf("%s (%.0f%%)", c.processor.Name(), c.confidence*100)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, "detect_"+c.processor.CallbackID()),
+		))
+	}
+	msg := tgbotapi.NewMessage(chatID, "Не удалось однозначно определить тип файла. Какой это отчет?")
+	msg.ReplyMarkup = tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+	sent, err := bot.Send(msg)
+	if err != nil {
+		slog.Error("Не удалось отправить выбор типа файла", "chat_id", chatID, "err", err)
+		return
+	}
+	pendingFilesMu.Lock()
+	pendingFiles[pendingKey{chatID: chatID, messageID: sent.MessageID}] = file
+	pendingFilesMu.Unlock()
+}
+
+func handleDetectCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	chatID := callback.Message.Chat.ID
+	processor := processorByCallback(strings.TrimPrefix(callback.Data, "detect_"))
+
+	key := pendingKey{chatID: chatID, messageID: callback.Message.MessageID}
+	pendingFilesMu.Lock()
+	file, ok := pendingFiles[key]
+	delete(pendingFiles, key)
+	pendingFilesMu.Unlock()
+
+	if processor == nil || !ok {
+		bot.Request(tgbotapi.NewCallback(callback.ID, "Файл уже обработан, отправьте его еще раз"))
+		return
+	}
+	bot.Request(tgbotapi.NewCallback(callback.ID, processor.Name()))
+	bot.Send(tgbotapi.NewDeleteMessage(chatID, callback.Message.MessageID))
+	file.processor = processor
+	processDocument(bot, chatID, file)
+}
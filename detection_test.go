@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestDetermineFileTypeSamples(t *testing.T) {
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"Расписание групп.xls", "mode_schedule"},
+		{"Темы уроков.xlsx", "mode_lessons"},
+		{"Отчет по студентам.xlsx", "mode_students"},
+		{"Посещаемость по преподавателям.xlsx", "mode_attendance"},
+		{"Отчет по домашним заданиям.xlsx", "mode_checked_homework"},
+		// Копия выгрузки по студентам, колонки посещаемости в ней нет
+		{"Отчет по посещаемости студентов.xlsx", "mode_students"},
+	}
+	for _, tt := range tests {
+		candidates := determineFileType("Tz-for-tg-bot/" + tt.file)
+		processor, choices := pickFileType(candidates)
+		if processor == nil {
+			t.Errorf("%s: тип не определен, варианты %v", tt.file, choices)
+			continue
+		}
+		if processor.CallbackID() != tt.want {
+			t.Errorf("%s: %s, ожидался %s", tt.file, processor.CallbackID(), tt.want)
+		}
+	}
+}
+
+func TestDetectSubmittedHomeworkExport(t *testing.T) {
+	// Выгрузка только с процентом выполнения ДЗ — отчет по сданным ДЗ,
+	// несмотря на слово homework в названии колонки
+	header := normalizeHeader([]string{"FIO", "Группа", "Percentage Homework"})
+	submitted := processorByCallback("mode_submitted_homework").Detect(header)
+	students := processorByCallback("mode_students").Detect(header)
+	if submitted-students < detectAmbiguityGap {
+		t.Errorf("сданные ДЗ %.2f, студенты %.2f: тип не определяется однозначно", submitted, students)
+	}
+}
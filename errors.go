@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Ошибки обработки файлов, о которых пользователю сообщается понятным
+// текстом, а не текстом ошибки Go. Обработчики возвращают их как ошибки,
+// а не как отчет из одного сообщения, чтобы вызывающий код мог отличить
+// неудачу от отчета без замечаний
+
+// В файле или на листе нет строк с данными
+var errEmptySheet = errors.New("нет данных в файле")
+
+// В заголовке не найдены обязательные колонки (ключи полей из columnFields).
+// suggestions — названия типов отчетов, на которые файл похож по заголовкам
+type missingColumnsError struct {
+	fields      []string
+	suggestions []string
+}
+
+func (e *missingColumnsError) Error() string {
+	return "не найдены колонки: " + strings.Join(e.fields, ", ")
+}
+
+// Файл не является книгой Excel или сохранен в формате, который не читается
+type unsupportedFormatError struct {
+	reason string
+}
+
+func (e *unsupportedFormatError) Error() string {
+	return "неподдерживаемый формат файла: " + e.reason
+}
+
+// Файл больше наибольшего разрешенного размера. Размер неизвестен (0),
+// если превышение обнаружилось только при скачивании
+type fileTooLargeError struct {
+	size, limit int64
+}
+
+func (e *fileTooLargeError) Error() string {
+	return fmt.Sprintf("файл больше %d МБ", e.limit>>20)
+}
+
+// Ошибки, после которых обработку остальных листов книги можно продолжить
+func isSheetSkippable(err error) bool {
+	var missing *missingColumnsError
+	return errors.Is(err, errEmptySheet) || errors.As(err, &missing)
+}
+
+// Вид ошибки обработки для метрик
+func errorType(err error) string {
+	var missing *missingColumnsError
+	var unsupported *unsupportedFormatError
+	var tooLarge *fileTooLargeError
+	switch {
+	case errors.Is(err, errEmptySheet):
+		return "empty_sheet"
+	case errors.As(err, &missing):
+		return "missing_columns"
+	case errors.As(err, &unsupported):
+		return "unsupported_format"
+	case errors.As(err, &tooLarge):
+		return "too_large"
+	}
+	return "processing"
+}
+
+// Сообщение для пользователя об ошибке обработки файла
+func userMessage(err error) string {
+	var missing *missingColumnsError
+	var unsupported *unsupportedFormatError
+	var tooLarge *fileTooLargeError
+	switch {
+	case errors.Is(err, errEmptySheet):
+		return "Нет данных в файле"
+	case errors.As(err, &missing):
+		var titles []string
+		for _, key := range missing.fields {
+			if f, ok := columnFieldByKey(key); ok {
+				titles = append(titles, fmt.Sprintf("%s (%s)", f.title, f.key))
+			}
+		}
+		text := fmt.Sprintf("Не найдены необходимые колонки: %s\n\n"+
+			"Если в файле колонка называется иначе, укажите ее командой\n/columns <поле> = <название колонки>",
+			strings.Join(titles, ", "))
+		if len(missing.suggestions) > 0 {
+			text += fmt.Sprintf("\n\nПохоже, это файл другого типа: %s. Выберите подходящий режим командой /setmode",
+				strings.Join(missing.suggestions, ", "))
+		}
+		return text
+	case errors.As(err, &unsupported):
+		return fmt.Sprintf("Не удалось открыть файл: %s. Отправьте книгу Excel в формате .xlsx или .xls (Excel 97 и новее)", unsupported.reason)
+	case errors.As(err, &tooLarge) && tooLarge.size > 0:
+		return fmt.Sprintf("Файл слишком большой: %.1f МБ. Можно отправить файл до %d МБ", float64(tooLarge.size)/(1<<20), tooLarge.limit>>20)
+	case errors.As(err, &tooLarge):
+		return fmt.Sprintf("Файл слишком большой. Можно отправить файл до %d МБ", tooLarge.limit>>20)
+	}
+	return fmt.Sprintf("Ошибка при обработке файла: %v", err)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Отчет в CSV для обработки скриптами: одна таблица, первая колонка — раздел
+func renderCSV(r *Report) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	var columns []string
+	for _, s := range r.Sections {
+		if len(s.Columns) > len(columns) {
+			columns = s.Columns
+		}
+	}
+	if err := w.Write(append([]string{"Раздел"}, columns...)); err != nil {
+		return nil, err
+	}
+	for _, s := range r.Sections {
+		for _, row := range s.Rows {
+			if err := w.Write(append([]string{s.Title}, row.Cells...)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// Структура JSON-отчета; строки — объекты с ключами по названиям колонок
+type jsonReport struct {
+	Report      string        `json:"report"`
+	Title       string        `json:"title"`
+	GeneratedAt time.Time     `json:"generated_at"`
+	Intro       string        `json:"intro,omitempty"`
+	Sections    []jsonSection `json:"sections"`
+	Summary     string        `json:"summary,omitempty"`
+}
+
+type jsonSection struct {
+	Title   string           `json:"title"`
+	Columns []string         `json:"columns"`
+	Rows    []map[string]any `json:"rows"`
+}
+
+func renderJSON(r *Report) ([]byte, error) {
+	out := jsonReport{
+		Report:      r.Name,
+		Title:       r.Title,
+		GeneratedAt: time.Now(),
+		Intro:       r.Intro,
+		Sections:    []jsonSection{},
+		Summary:     r.Summary,
+	}
+	for _, s := range r.Sections {
+		section := jsonSection{Title: s.Title, Columns: s.Columns, Rows: []map[string]any{}}
+		for _, row := range s.Rows {
+			values := make(map[string]any, len(row.Cells))
+			for i, cell := range row.Cells {
+				if i >= len(s.Columns) {
+					break
+				}
+				// Числа остаются числами, чтобы их не приходилось разбирать из строк
+				if v, err := strconv.ParseFloat(cell, 64); err == nil {
+					values[s.Columns[i]] = v
+				} else {
+					values[s.Columns[i]] = cell
+				}
+			}
+			section.Rows = append(section.Rows, values)
+		}
+		out.Sections = append(out.Sections, section)
+	}
+	return json.MarshalIndent(out, "", "  ")
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+)
+
+// Размеры страницы A4 и поля, в пунктах
+const (
+	pdfPageWidth  = 595.28
+	pdfPageHeight = 841.89
+	pdfMargin     = 40.0
+	pdfFooter     = 20.0
+	pdfTableSize  = 9.0
+	pdfCellPad    = 3.0
+)
+
+// Стандартные шрифты PDF не содержат кириллицы, поэтому в документ
+// встраивается TrueType-шрифт из системы. Путь можно задать переменной
+// окружения pdf_font, иначе ищется один из распространенных шрифтов
+var pdfFontCandidates = []string{
+	`C:\Windows\Fonts\arial.ttf`,
+	`C:\Windows\Fonts\tahoma.ttf`,
+	"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+	"/usr/share/fonts/TTF/DejaVuSans.ttf",
+	"/usr/share/fonts/dejavu/DejaVuSans.ttf",
+	"/Library/Fonts/Arial.ttf",
+	"/System/Library/Fonts/Supplemental/Arial.ttf",
+}
+
+var (
+	pdfFontOnce sync.Once
+	pdfFont     *ttfFont
+	pdfFontErr  error
+)
+
+// Шрифт загружается один раз при первом формировании PDF
+func loadPDFFont() (*ttfFont, error) {
+	pdfFontOnce.Do(func() {
+		if path := os.Getenv("pdf_font"); path != "" {
+			pdfFont, pdfFontErr = loadTTF(path)
+			return
+		}
+		for _, path := range pdfFontCandidates {
+			if _, err := os.Stat(path); err == nil {
+				pdfFont, pdfFontErr = loadTTF(path)
+				return
+			}
+		}
+		pdfFontErr = fmt.Errorf("не найден шрифт для PDF, укажите путь к .ttf в переменной pdf_font")
+	})
+	return pdfFont, pdfFontErr
+}
+
+// Отчет в виде PDF: заголовок, дата, таблицы отмеченных строк по разделам,
+// итоги и номера страниц
+func renderPDF(r *Report) ([]byte, error) {
+	font, err := loadPDFFont()
+	if err != nil {
+		return nil, err
+	}
+	d := &pdfDoc{font: font, used: make(map[uint16]rune)}
+	d.newPage()
+
+	d.paragraph(r.Title, 16, true)
+	d.paragraph("Дата формирования: "+time.Now().Format("02.01.2006 15:04"), 10, false)
+	d.space(6)
+	if r.Intro != "" {
+		d.paragraph(r.Intro, 11, false)
+		d.space(4)
+	}
+	total := 0
+	for _, s := range r.Sections {
+		d.space(6)
+		d.paragraph(s.Title, 12, true)
+		d.space(2)
+		d.table(s.Columns, s.Rows)
+		total += len(s.Rows)
+	}
+	d.space(10)
+	d.paragraph(fmt.Sprintf("Итого строк в отчете: %d", total), 11, true)
+	if r.Summary != "" {
+		d.paragraph(r.Summary, 11, false)
+	}
+	return d.bytes()
+}
+
+// Документ в процессе верстки: содержимое страниц и использованные глифы
+type pdfDoc struct {
+	font  *ttfFont
+	pages []*bytes.Buffer
+	y     float64
+	used  map[uint16]rune
+}
+
+func (d *pdfDoc) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pdfPageHeight - pdfMargin
+}
+
+func (d *pdfDoc) page() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+// Переход на новую страницу, если блок высотой h не помещается
+func (d *pdfDoc) ensure(h float64) bool {
+	if d.y-h < pdfMargin+pdfFooter {
+		d.newPage()
+		return true
+	}
+	return false
+}
+
+func (d *pdfDoc) space(h float64) {
+	d.y -= h
+}
+
+// Строка текста с левым краем x и базовой линией y. Полужирное начертание
+// имитируется обводкой контура, так как встраивается один шрифт
+func (d *pdfDoc) text(x, y float64, text string, size float64, bold bool) {
+	d.textOn(d.page(), x, y, text, size, bold)
+}
+
+func (d *pdfDoc) textOn(page *bytes.Buffer, x, y float64, text string, size float64, bold bool) {
+	var hex strings.Builder
+	for _, r := range text {
+		gid := d.font.glyph(r)
+		if gid == 0 {
+			continue
+		}
+		d.used[gid] = r
+		fmt.Fprintf(&hex, "%04X", gid)
+	}
+	if hex.Len() == 0 {
+		return
+	}
+	mode := "0 Tr"
+	if bold {
+		mode = fmt.Sprintf("2 Tr %s w", pdfNum(size/30))
+	}
+	fmt.Fprintf(page, "BT /F1 %s Tf %s %s %s Td <%s> Tj ET\n", pdfNum(size), mode, pdfNum(x), pdfNum(y), hex.String())
+}
+
+// Абзац во всю ширину страницы с переносом по словам
+func (d *pdfDoc) paragraph(text string, size float64, bold bool) {
+	lineHeight := size * 1.3
+	for _, line := range d.wrap(text, pdfPageWidth-2*pdfMargin, size) {
+		d.ensure(lineHeight)
+		d.y -= lineHeight
+		d.text(pdfMargin, d.y+size*0.25, line, size, bold)
+	}
+}
+
+// Таблица с номером строки, заголовком на каждой странице и переносом текста в ячейках
+func (d *pdfDoc) table(columns []string, rows []ReportRow) {
+	columns = append([]string{"№"}, columns...)
+	cells := make([][]string, len(rows))
+	for i, row := range rows {
+		cells[i] = append([]string{strconv.Itoa(i + 1)}, row.Cells...)
+	}
+	widths := d.columnWidths(columns, cells)
+	lineHeight := pdfTableSize * 1.25
+
+	var drawRow func(values []string, header bool)
+	drawRow = func(values []string, header bool) {
+		wrapped := make([][]string, len(widths))
+		lines := 1
+		for i := range widths {
+			value := ""
+			if i < len(values) {
+				value = values[i]
+			}
+			wrapped[i] = d.wrap(value, widths[i]-2*pdfCellPad, pdfTableSize)
+			lines = max(lines, len(wrapped[i]))
+		}
+		h := float64(lines)*lineHeight + 2*pdfCellPad
+		if d.ensure(h) && !header {
+			drawRow(columns, true)
+		}
+		top := d.y
+		if header {
+			fmt.Fprintf(d.page(), "0.87 0.92 0.97 rg %s %s %s %s re f 0 g\n",
+				pdfNum(pdfMargin), pdfNum(top-h), pdfNum(sum(widths)), pdfNum(h))
+		}
+		x := pdfMargin
+		for i, w := range widths {
+			for l, line := range wrapped[i] {
+				baseline := top - pdfCellPad - float64(l+1)*lineHeight + pdfTableSize*0.3
+				d.text(x+pdfCellPad, baseline, line, pdfTableSize, header)
+			}
+			fmt.Fprintf(d.page(), "0.6 G 0.5 w %s %s %s %s re S 0 G\n", pdfNum(x), pdfNum(top-h), pdfNum(w), pdfNum(h))
+			x += w
+		}
+		d.y -= h
+	}
+	drawRow(columns, true)
+	for _, row := range cells {
+		drawRow(row, false)
+	}
+}
+
+// Ширины колонок пропорциональны самому длинному тексту, но не уже 30 пт
+func (d *pdfDoc) columnWidths(columns []string, rows [][]string) []float64 {
+	available := pdfPageWidth - 2*pdfMargin
+	natural := make([]float64, len(columns))
+	for i, c := range columns {
+		natural[i] = d.font.textWidth(c, pdfTableSize) + 2*pdfCellPad
+	}
+	for _, row := range rows {
+		for i := 0; i < len(row) && i < len(natural); i++ {
+			natural[i] = max(natural[i], d.font.textWidth(row[i], pdfTableSize)+2*pdfCellPad)
+		}
+	}
+	total := sum(natural)
+	if total <= available {
+		// Лишнее место отдается последней колонке, чтобы таблица была во всю ширину
+		natural[len(natural)-1] += available - total
+		return natural
+	}
+	widths := make([]float64, len(natural))
+	for i, n := range natural {
+		widths[i] = max(30, available*n/total)
+	}
+	scale := available / sum(widths)
+	for i := range widths {
+		widths[i] *= scale
+	}
+	return widths
+}
+
+// Перенос текста по словам; слишком длинные слова режутся по символам.
+// Символы, которых нет в шрифте (например, эмодзи в заголовках), отбрасываются
+func (d *pdfDoc) wrap(text string, width, size float64) []string {
+	text = strings.Map(func(r rune) rune {
+		if r == '\n' || d.font.glyph(r) != 0 {
+			return r
+		}
+		return -1
+	}, text)
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if d.font.textWidth(candidate, size) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			line = ""
+			for _, r := range word {
+				if line != "" && d.font.textWidth(line+string(r), size) > width {
+					lines = append(lines, line)
+					line = ""
+				}
+				line += string(r)
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Сборка файла: каталог, страницы с номерами, встроенный шрифт
+func (d *pdfDoc) bytes() ([]byte, error) {
+	// Общее число страниц известно только после верстки
+	for i, page := range d.pages {
+		footer := fmt.Sprintf("Стр. %d из %d", i+1, len(d.pages))
+		x := (pdfPageWidth - d.font.textWidth(footer, 8)) / 2
+		d.textOn(page, x, pdfMargin/2, footer, 8, false)
+	}
+
+	w := &pdfWriter{}
+	w.buf.WriteString("%PDF-1.4\n%\xE2\xE3\xCF\xD3\n")
+
+	// Номера объектов: 1 — каталог, 2 — дерево страниц, 3..7 — шрифт, далее страницы
+	const catalogID, pagesID, fontID, cidFontID, descriptorID, fontFileID, toUnicodeID = 1, 2, 3, 4, 5, 6, 7
+	firstPageID := 8
+
+	w.object(catalogID, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesID))
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPageID+i*2)
+	}
+	w.object(pagesID, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+
+	f := d.font
+	w.object(fontID, fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /ReportFont /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>", cidFontID, toUnicodeID))
+	w.object(cidFontID, fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /ReportFont "+
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> "+
+		"/FontDescriptor %d 0 R /DW 1000 /W [%s] /CIDToGIDMap /Identity >>", descriptorID, d.widthsArray()))
+	w.object(descriptorID, fmt.Sprintf("<< /Type /FontDescriptor /FontName /ReportFont /Flags 32 /FontBBox [%d %d %d %d] "+
+		"/ItalicAngle %s /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		f.scale(f.bbox[0]), f.scale(f.bbox[1]), f.scale(f.bbox[2]), f.scale(f.bbox[3]),
+		pdfNum(f.italicAngle), f.scale(f.ascent), f.scale(f.descent), f.scale(f.capHeight), fontFileID))
+	if err := w.stream(fontFileID, fmt.Sprintf("/Length1 %d", len(f.data)), f.data); err != nil {
+		return nil, err
+	}
+	if err := w.stream(toUnicodeID, "", d.toUnicode()); err != nil {
+		return nil, err
+	}
+
+	for i, content := range d.pages {
+		pageID := firstPageID + i*2
+		w.object(pageID, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] "+
+			"/Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>",
+			pagesID, pdfNum(pdfPageWidth), pdfNum(pdfPageHeight), fontID, pageID+1))
+		if err := w.stream(pageID+1, "", content.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+	return w.finish(catalogID), nil
+}
+
+// Ширины использованных глифов в формате массива /W
+func (d *pdfDoc) widthsArray() string {
+	gids := d.usedGlyphs()
+	var b strings.Builder
+	for _, gid := range gids {
+		fmt.Fprintf(&b, "%d [%s] ", gid, pdfNum(d.font.glyphWidth(gid)))
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// Таблица соответствия глифов символам, чтобы текст из PDF можно было копировать и искать
+func (d *pdfDoc) toUnicode() []byte {
+	gids := d.usedGlyphs()
+	var b strings.Builder
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	for start := 0; start < len(gids); start += 100 {
+		chunk := gids[start:min(start+100, len(gids))]
+		fmt.Fprintf(&b, "%d beginbfchar\n", len(chunk))
+		for _, gid := range chunk {
+			var units strings.Builder
+			for _, u := range utf16.Encode([]rune{d.used[gid]}) {
+				fmt.Fprintf(&units, "%04X", u)
+			}
+			fmt.Fprintf(&b, "<%04X> <%s>\n", gid, units.String())
+		}
+		b.WriteString("endbfchar\n")
+	}
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return []byte(b.String())
+}
+
+func (d *pdfDoc) usedGlyphs() []uint16 {
+	gids := make([]uint16, 0, len(d.used))
+	for gid := range d.used {
+		gids = append(gids, gid)
+	}
+	sort.Slice(gids, func(i, j int) bool { return gids[i] < gids[j] })
+	return gids
+}
+
+// Последовательная запись объектов PDF с таблицей смещений в конце
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets map[int]int
+}
+
+func (w *pdfWriter) object(id int, body string) {
+	if w.offsets == nil {
+		w.offsets = make(map[int]int)
+	}
+	w.offsets[id] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n%s\nendobj\n", id, body)
+}
+
+// Поток, сжатый zlib (FlateDecode)
+func (w *pdfWriter) stream(id int, extra string, data []byte) error {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if w.offsets == nil {
+		w.offsets = make(map[int]int)
+	}
+	w.offsets[id] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n<< /Length %d /Filter /FlateDecode %s >>\nstream\n", id, compressed.Len(), extra)
+	w.buf.Write(compressed.Bytes())
+	w.buf.WriteString("\nendstream\nendobj\n")
+	return nil
+}
+
+func (w *pdfWriter) finish(rootID int) []byte {
+	count := 0
+	for id := range w.offsets {
+		count = max(count, id)
+	}
+	xref := w.buf.Len()
+	fmt.Fprintf(&w.buf, "xref\n0 %d\n0000000000 65535 f \n", count+1)
+	for id := 1; id <= count; id++ {
+		fmt.Fprintf(&w.buf, "%010d 00000 n \n", w.offsets[id])
+	}
+	fmt.Fprintf(&w.buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", count+1, rootID, xref)
+	return w.buf.Bytes()
+}
+
+// Число без экспоненты и лишних нулей, как требует синтаксис PDF
+func pdfNum(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" || s == "" {
+		return "0"
+	}
+	return s
+}
+
+func sum(values []float64) float64 {
+	var total float64
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	excelize "github.com/xuri/excelize/v2"
+)
+
+// Листы файла с отчетом
+const (
+	xlsxSummarySheet = "Сводка"
+	xlsxDataSheet    = "Данные"
+)
+
+// Отчет в виде книги Excel: сводка по разделам и таблица отмеченных строк
+// с фильтром и закрепленным заголовком
+func renderXLSX(r *Report) ([]byte, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	titleStyle, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true, Size: 14}})
+	if err != nil {
+		return nil, err
+	}
+	headerStyle, err := f.NewStyle(&excelize.Style{
+		Font:      &excelize.Font{Bold: true},
+		Fill:      excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"DDEBF7"}},
+		Alignment: &excelize.Alignment{Vertical: "center", WrapText: true},
+		Border: []excelize.Border{
+			{Type: "bottom", Color: "9BC2E6", Style: 1},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.SetSheetName("Sheet1", xlsxSummarySheet); err != nil {
+		return nil, err
+	}
+	if err := writeXLSXSummary(f, r, titleStyle, headerStyle); err != nil {
+		return nil, err
+	}
+	if _, err := f.NewSheet(xlsxDataSheet); err != nil {
+		return nil, err
+	}
+	if err := writeXLSXData(f, r, headerStyle); err != nil {
+		return nil, err
+	}
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeXLSXSummary(f *excelize.File, r *Report, titleStyle, headerStyle int) error {
+	sheet := xlsxSummarySheet
+	lines := [][]any{
+		{r.Title},
+		{"Дата формирования: " + time.Now().Format("02.01.2006 15:04")},
+	}
+	if r.Intro != "" {
+		lines = append(lines, []any{r.Intro})
+	}
+	lines = append(lines, []any{})
+	headerRow := len(lines) + 1
+	lines = append(lines, []any{"Раздел", "Строк"})
+	total := 0
+	for _, s := range r.Sections {
+		lines = append(lines, []any{s.Title, len(s.Rows)})
+		total += len(s.Rows)
+	}
+	lines = append(lines, []any{"Всего", total})
+	if r.Summary != "" {
+		lines = append(lines, []any{}, []any{r.Summary})
+	}
+
+	for i, line := range lines {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow(sheet, cell, &line); err != nil {
+			return err
+		}
+	}
+	if err := f.SetCellStyle(sheet, "A1", "A1", titleStyle); err != nil {
+		return err
+	}
+	left, _ := excelize.CoordinatesToCellName(1, headerRow)
+	right, _ := excelize.CoordinatesToCellName(2, headerRow)
+	if err := f.SetCellStyle(sheet, left, right, headerStyle); err != nil {
+		return err
+	}
+	return f.SetColWidth(sheet, "A", "A", 60)
+}
+
+// Все разделы в одной таблице; если разделов несколько, первая колонка — раздел
+func writeXLSXData(f *excelize.File, r *Report, headerStyle int) error {
+	sheet := xlsxDataSheet
+	withSection := len(r.Sections) > 1
+
+	var header []string
+	for _, s := range r.Sections {
+		if len(s.Columns) > len(header) {
+			header = s.Columns
+		}
+	}
+	if withSection {
+		header = append([]string{"Раздел"}, header...)
+	}
+	widths := make([]int, len(header))
+	for i, h := range header {
+		widths[i] = utf8.RuneCountInString(h)
+	}
+
+	rowNum := 1
+	headerCells := make([]any, len(header))
+	for i, h := range header {
+		headerCells[i] = h
+	}
+	if err := f.SetSheetRow(sheet, "A1", &headerCells); err != nil {
+		return err
+	}
+	for _, s := range r.Sections {
+		for _, row := range s.Rows {
+			cells := row.Cells
+			if withSection {
+				cells = append([]string{s.Title}, cells...)
+			}
+			values := make([]any, len(cells))
+			for i, c := range cells {
+				// Числа записываются числами, чтобы по ним работали сортировка и фильтр
+				if v, err := strconv.ParseFloat(c, 64); err == nil {
+					values[i] = v
+				} else {
+					values[i] = c
+				}
+				if i < len(widths) {
+					widths[i] = max(widths[i], utf8.RuneCountInString(c))
+				}
+			}
+			rowNum++
+			cell, _ := excelize.CoordinatesToCellName(1, rowNum)
+			if err := f.SetSheetRow(sheet, cell, &values); err != nil {
+				return err
+			}
+		}
+	}
+	if len(header) == 0 {
+		return nil
+	}
+
+	lastHeader, _ := excelize.CoordinatesToCellName(len(header), 1)
+	if err := f.SetCellStyle(sheet, "A1", lastHeader, headerStyle); err != nil {
+		return err
+	}
+	for i, w := range widths {
+		col, _ := excelize.ColumnNumberToName(i + 1)
+		if err := f.SetColWidth(sheet, col, col, float64(min(w+2, 60))); err != nil {
+			return err
+		}
+	}
+	if err := f.SetPanes(sheet, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		return err
+	}
+	lastCell, _ := excelize.CoordinatesToCellName(len(header), rowNum)
+	return f.AutoFilter(sheet, "A1:"+lastCell, nil)
+}
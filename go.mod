@@ -8,8 +8,9 @@ require github.com/joho/godotenv v1.5.1
 
 require github.com/xuri/excelize/v2 v2.10.0
 
+require github.com/richardlehane/mscfb v1.0.4
+
 require (
-	github.com/richardlehane/mscfb v1.0.4 // indirect
 	github.com/richardlehane/msoleps v1.0.4 // indirect
 	github.com/tiendc/go-deepcopy v1.7.1 // indirect
 	github.com/xuri/efp v0.0.1 // indirect
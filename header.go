@@ -0,0 +1,162 @@
+package main
+
+import "strings"
+
+// Сколько первых строк листа просматривается в поисках заголовка: над
+// таблицей в выгрузках бывают название отчета, период и пустые строки
+const headerScanRows = 10
+
+// Колонка, которую обработчик ищет в заголовке таблицы: поле из словаря
+// columnFields. Для двухуровневого заголовка подходит и полное название
+// «домашние задания / проверено», и любой из уровней
+type headerColumn struct {
+	field    string
+	required bool
+}
+
+// Найденный заголовок: номера колонок по полям и первая строка данных
+type headerLayout struct {
+	columns   map[string]int
+	dataStart int
+}
+
+// Номер колонки или -1, если ее нет в заголовке
+func (h headerLayout) index(field string) int {
+	if i, ok := h.columns[field]; ok {
+		return i
+	}
+	return -1
+}
+
+// Поиск строки заголовка, лучше всего совпадающей с ожидаемыми колонками.
+// Каждая строка пробуется как одноуровневый заголовок и вместе со следующей
+// как двухуровневый («Месяц» над «Проверено»). Объединенные ячейки к этому
+// моменту уже развернуты при чтении книги. Названия колонок, указанные
+// пользователем для полей, берутся из overrides. Если ни в одной строке не
+// нашлись все обязательные колонки, возвращает поля, которых не хватило
+// в лучшей из строк
+func locateHeader(rows [][]string, columns []headerColumn, overrides map[string][]string) (headerLayout, []string) {
+	var best headerLayout
+	bestScore := -1
+	var missing []string
+	missingScore := -1
+	for i := 0; i < min(len(rows), headerScanRows); i++ {
+		for levels := 1; levels <= 2 && i+levels <= len(rows); levels++ {
+			labels := headerLabels(rows[i : i+levels])
+			found, lost, score := matchHeader(labels, columns, overrides)
+			// При равенстве остается вариант выше и с меньшим числом уровней
+			if len(lost) == 0 && score > bestScore {
+				best = headerLayout{columns: found, dataStart: i + levels}
+				bestScore = score
+			}
+			if len(lost) > 0 && score > missingScore {
+				missing, missingScore = lost, score
+			}
+		}
+	}
+	if bestScore < 0 {
+		if missing == nil {
+			for _, col := range columns {
+				if col.required {
+					missing = append(missing, col.field)
+				}
+			}
+		}
+		return headerLayout{}, missing
+	}
+	// Строки, повторяющие заголовок (остаток объединенных по вертикали ячеек), пропускаются
+	for best.dataStart < len(rows) && repeatsHeader(rows[best.dataStart], rows[best.dataStart-1]) {
+		best.dataStart++
+	}
+	return best, nil
+}
+
+// Названия колонок по уровням заголовка. Одинаковые уровни (ячейка,
+// объединенная по вертикали) и пустые уровни схлопываются
+func headerLabels(rows [][]string) [][]string {
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	labels := make([][]string, width)
+	for c := range labels {
+		for _, row := range rows {
+			if c >= len(row) {
+				continue
+			}
+			level := normalizeColumnName(row[c])
+			if level == "" || (len(labels[c]) > 0 && labels[c][len(labels[c])-1] == level) {
+				continue
+			}
+			labels[c] = append(labels[c], level)
+		}
+	}
+	return labels
+}
+
+// Сопоставление полей с колонками: каждому полю — еще не занятая колонка
+// с лучшим совпадением, при равенстве левая. Оценка — сумма качества
+// совпадений, поэтому точные названия перевешивают похожие
+func matchHeader(labels [][]string, columns []headerColumn, overrides map[string][]string) (map[string]int, []string, int) {
+	found := make(map[string]int)
+	used := make(map[int]bool)
+	var missing []string
+	score := 0
+	for _, col := range columns {
+		field, ok := columnFieldByKey(col.field)
+		if !ok {
+			continue
+		}
+		bestCol, bestQuality := -1, matchNone
+		for c, levels := range labels {
+			if used[c] {
+				continue
+			}
+			if q := labelQuality(levels, field, overrides[col.field]); q > bestQuality {
+				bestCol, bestQuality = c, q
+			}
+		}
+		if bestCol < 0 {
+			if col.required {
+				missing = append(missing, col.field)
+			}
+			continue
+		}
+		found[col.field] = bestCol
+		used[bestCol] = true
+		score += bestQuality
+	}
+	return found, missing, score
+}
+
+// Лучшее совпадение поля с колонкой по полному названию или по одному из уровней
+func labelQuality(levels []string, field columnField, overrides []string) int {
+	if len(levels) == 0 {
+		return matchNone
+	}
+	candidates := levels
+	if len(levels) > 1 {
+		candidates = append([]string{strings.Join(levels, " / ")}, levels...)
+	}
+	best := matchNone
+	for _, label := range candidates {
+		best = max(best, matchColumnName(label, field, overrides))
+	}
+	return best
+}
+
+// Строка повторяет заголовок, если все ее непустые ячейки совпадают
+// с ячейками строки над ней
+func repeatsHeader(row, above []string) bool {
+	nonEmpty := false
+	for c, cell := range row {
+		if strings.TrimSpace(cell) == "" {
+			continue
+		}
+		if c >= len(above) || cell != above[c] {
+			return false
+		}
+		nonEmpty = true
+	}
+	return nonEmpty
+}
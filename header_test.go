@@ -0,0 +1,200 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Первые строки листов из Tz-for-tg-bot в том виде, в каком их отдает
+// sheetReader: объединенные ячейки уже развернуты
+var (
+	homeworkReportHead = [][]string{
+		{"Форма обучения", "ФИО преподавателя", "Месяц", "Месяц", "Месяц", "Месяц", "Месяц", "Неделя", "Неделя", "Неделя", "Неделя", "Неделя", "День", "День", "День", "День", "День"},
+		{"Форма обучения", "ФИО преподавателя", "Кол-во пар", "Выдано", "Получено", "Проверено", "План", "Кол-во пар", "Выдано", "Получено", "Проверено", "План", "Кол-во пар", "Выдано", "Получено", "Проверено", "План"},
+		{"Колледж", "Аган Эмрах", "107", "0", "0", "0", "1673", "28", "0", "0", "0", "433", "2", "0", "0", "0", "37"},
+	}
+	teacherAttendanceHead = [][]string{
+		{"ФИО преподавателя", "Индивидуальные занятия", "Индивидуальные занятия", "Индивидуальные занятия", "Колледж", "Колледж", "Колледж", "СК&lt;4", "СК&lt;4", "СК&lt;4", "Средняя посещаемость", "Всего пар", "Всего групп"},
+		{},
+		{"Аган Эмрах", "", "", "", "73", "368", "7", "100", "3", "1", "73%", "371", "8"},
+	}
+	lessonsHead = [][]string{
+		{"Date", "Лента", "Предмет", "Группа", "ФИО преподавателя", "Тема урока"},
+		{"2025-12-15", "1", "Autodesk 3ds Max. Основы 3D-моделирования", "9/2-ГД-24/3", "Иванов Никита Андреевич", "Урок 3. Разбор стандартных примитивов."},
+	}
+	studentsHead = [][]string{
+		{"FIO", "Поток", "Группа", "Survey result", "Наличие фото", "Визит в Journal", "pairs in total", "pairs in total per month", "Рейтинг", "Топгемы", "Топкоины", "Топгемы", "Топкоины", "Number of failed exams", "Debt", "Homework", "Classroom", "Average score", "Percentage of Classroom Grades per month", "Percentage Homework", "Percentage Homework", "Number of homework to be checked", "Number of homework to be checked per month", "% of passed tests for the entire period per month", "Number of reviews from teachers in the last 60 days", "% probability of loss"},
+		{"Расхожев Вячеслав Евгеньевич ", "Колледж Осень 2022", "9/4-ГД-22/1", "-", "ДА", "2025-12-14 15:34:33", "3277", "57", "11", "3506", "3906", "1125", "1167", "3", "нет", "5", "9", "7", "-", "49", "-", "0", "0", "-", "5", "-"},
+	}
+	scheduleHead = [][]string{
+		{"Группа", "Пара", "Время", "Понедельник. 15.12.2025", "Время", "Вторник. 16.12.2025"},
+		{"9/3-РПО-23/2", "0"},
+		{"9/3-РПО-23/2", "1", "", "", "", ""},
+	}
+)
+
+func TestLocateHeader(t *testing.T) {
+	tests := []struct {
+		name      string
+		rows      [][]string
+		columns   []headerColumn
+		overrides map[string][]string
+		want      map[string]int
+		dataStart int
+		missing   []string
+	}{
+		{
+			// «Месяц» над «Получено» и «Проверено»: берутся колонки за месяц
+			name: "двухуровневый заголовок отчета по ДЗ",
+			rows: homeworkReportHead,
+			columns: []headerColumn{
+				{field: "teacher", required: true},
+				{field: "checked", required: true},
+				{field: "received", required: true},
+			},
+			want:      map[string]int{"teacher": 1, "checked": 5, "received": 4},
+			dataStart: 2,
+		},
+		{
+			name: "посещаемость преподавателей с пустой строкой под заголовком",
+			rows: teacherAttendanceHead,
+			columns: []headerColumn{
+				{field: "teacher", required: true},
+				{field: "attendance", required: true},
+			},
+			want:      map[string]int{"teacher": 0, "attendance": 10},
+			dataStart: 1,
+		},
+		{
+			name:      "темы уроков",
+			rows:      lessonsHead,
+			columns:   []headerColumn{{field: "topic", required: true}},
+			want:      map[string]int{"topic": 5},
+			dataStart: 1,
+		},
+		{
+			name: "студенты: оценки",
+			rows: studentsHead,
+			columns: []headerColumn{
+				{field: "fio", required: true},
+				{field: "homework"},
+				{field: "classwork"},
+			},
+			want:      map[string]int{"fio": 0, "homework": 15, "classwork": 16},
+			dataStart: 1,
+		},
+		{
+			name: "студенты: процент ДЗ",
+			rows: studentsHead,
+			columns: []headerColumn{
+				{field: "fio", required: true},
+				{field: "homework_percent", required: true},
+			},
+			want:      map[string]int{"fio": 0, "homework_percent": 19},
+			dataStart: 1,
+		},
+		{
+			name: "студенты: колонки посещаемости нет",
+			rows: studentsHead,
+			columns: []headerColumn{
+				{field: "fio", required: true},
+				{field: "group"},
+				{field: "attendance", required: true},
+			},
+			missing: []string{"attendance"},
+		},
+		{
+			name: "расписание",
+			rows: scheduleHead,
+			columns: []headerColumn{
+				{field: "group", required: true},
+				{field: "pair", required: true},
+				{field: "time", required: true},
+			},
+			want:      map[string]int{"group": 0, "pair": 1, "time": 2},
+			dataStart: 1,
+		},
+		{
+			name: "название отчета над таблицей",
+			rows: [][]string{
+				{"Отчет по посещаемости студентов"},
+				{"Период: декабрь 2025"},
+				{},
+				{"ФИО", "Группа", "Посещаемость, %"},
+				{"Иванов Иван", "ИС-21", "45"},
+			},
+			columns: []headerColumn{
+				{field: "fio", required: true},
+				{field: "group"},
+				{field: "attendance", required: true},
+			},
+			want:      map[string]int{"fio": 0, "group": 1, "attendance": 2},
+			dataStart: 4,
+		},
+		{
+			// Ячейки, объединенные по вертикали, развернуты в строку под заголовком
+			name: "повтор заголовка пропускается",
+			rows: [][]string{
+				{"ФИО", "Посещаемость"},
+				{"ФИО", "Посещаемость"},
+				{"Иванов Иван", "45"},
+			},
+			columns: []headerColumn{
+				{field: "fio", required: true},
+				{field: "attendance", required: true},
+			},
+			want:      map[string]int{"fio": 0, "attendance": 1},
+			dataStart: 2,
+		},
+		{
+			name: "название колонки из /columns",
+			rows: [][]string{
+				{"Слушатель", "Группа", "Явка"},
+				{"Иванов Иван", "ИС-21", "45"},
+			},
+			columns: []headerColumn{
+				{field: "fio", required: true},
+				{field: "attendance", required: true},
+			},
+			overrides: map[string][]string{"fio": {"слушатель"}, "attendance": {"Явка"}},
+			want:      map[string]int{"fio": 0, "attendance": 2},
+			dataStart: 1,
+		},
+		{
+			name:    "пустой лист",
+			columns: []headerColumn{{field: "topic", required: true}},
+			missing: []string{"topic"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, missing := locateHeader(tt.rows, tt.columns, tt.overrides)
+			if !reflect.DeepEqual(missing, tt.missing) {
+				t.Fatalf("не найдены: %q, ожидалось %q", missing, tt.missing)
+			}
+			if tt.missing != nil {
+				return
+			}
+			if !reflect.DeepEqual(header.columns, tt.want) {
+				t.Errorf("колонки: %v, ожидалось %v", header.columns, tt.want)
+			}
+			if header.dataStart != tt.dataStart {
+				t.Errorf("первая строка данных: %d, ожидалась %d", header.dataStart, tt.dataStart)
+			}
+		})
+	}
+}
+
+func TestHeaderLabels(t *testing.T) {
+	got := headerLabels(homeworkReportHead[:2])
+	want := [][]string{
+		{"форма обучения"},
+		{"фио преподавателя"},
+		{"месяц", "кол во пар"},
+		{"месяц", "выдано"},
+		{"месяц", "получено"},
+	}
+	if !reflect.DeepEqual(got[:len(want)], want) {
+		t.Errorf("уровни заголовка: %q, ожидалось %q", got[:len(want)], want)
+	}
+}
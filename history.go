@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Файл с историей отчетов по умолчанию и сколько загрузок каждого типа хранить
+const (
+	defaultHistoryPath = "report_history.json"
+	historyLimit       = 10
+)
+
+// Сохраненный результат обработки одного файла
+type historyEntry struct {
+	Time   time.Time `json:"time"`
+	Report *Report   `json:"report"`
+}
+
+// История отчетов по чатам и типам отчетов (CallbackID обработчика).
+// Файл перезаписывается целиком через временный, как и настройки чатов
+type historyStore struct {
+	mu    sync.Mutex
+	path  string
+	chats map[int64]map[string][]historyEntry
+}
+
+var history *historyStore
+
+func historyPath() string {
+	if path := os.Getenv("history_path"); path != "" {
+		return path
+	}
+	return defaultHistoryPath
+}
+
+// Загрузка истории при запуске бота; отсутствие файла — пустая история
+func loadReportHistory(path string) error {
+	h := &historyStore{path: path, chats: make(map[int64]map[string][]historyEntry)}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &h.chats); err != nil {
+			return fmt.Errorf("файл %s поврежден: %w", path, err)
+		}
+	}
+	history = h
+	return nil
+}
+
+// Сохранение отчета в историю чата. Отчеты из одного сообщения (без
+// заголовка) не сохраняются
+func recordReport(chatID int64, kind string, report *Report) {
+	if history == nil || report.Title == "" {
+		return
+	}
+	if err := history.add(chatID, kind, historyEntry{Time: time.Now(), Report: report}); err != nil {
+		slog.Error("Не удалось сохранить историю отчетов", "chat_id", chatID, "err", err)
+	}
+}
+
+func (h *historyStore) add(chatID int64, kind string, entry historyEntry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.chats[chatID] == nil {
+		h.chats[chatID] = make(map[string][]historyEntry)
+	}
+	entries := append(h.chats[chatID][kind], entry)
+	if len(entries) > historyLimit {
+		entries = entries[len(entries)-historyLimit:]
+	}
+	h.chats[chatID][kind] = entries
+
+	data, err := json.Marshal(h.chats)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(h.path, data)
+}
+
+// Последние n загрузок отчета, от старых к новым
+func (h *historyStore) last(chatID int64, kind string, n int) []historyEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entries := h.chats[chatID][kind]
+	return append([]historyEntry(nil), entries[max(len(entries)-n, 0):]...)
+}
+
+// Типы отчетов в порядке processors, по которым в чате есть хотя бы n загрузок
+func (h *historyStore) kinds(chatID int64, n int) []Processor {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var result []Processor
+	for _, p := range processors {
+		if len(h.chats[chatID][p.CallbackID()]) >= n {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// Строка отчета для сравнения: текстовые ячейки (ФИО, группа, вид работы)
+// образуют ключ, последняя числовая ячейка — значение
+type rowFacts struct {
+	label    string
+	value    float64
+	hasValue bool
+}
+
+func reportRowFacts(r *Report) (map[string]rowFacts, []string) {
+	facts := make(map[string]rowFacts)
+	var order []string
+	for _, s := range r.Sections {
+		for _, row := range s.Rows {
+			var text []string
+			f := rowFacts{}
+			for _, cell := range row.Cells {
+				if v, ok := parseCellNumber(cell); ok {
+					f.value, f.hasValue = v, true
+				} else if cell = strings.TrimSpace(cell); cell != "" {
+					text = append(text, cell)
+				}
+			}
+			key := strings.Join(text, "\x1f")
+			if key == "" {
+				continue
+			}
+			if _, seen := facts[key]; seen {
+				continue
+			}
+			f.label = strings.Join(text, ", ")
+			facts[key] = f
+			order = append(order, key)
+		}
+	}
+	return facts, order
+}
+
+func parseCellNumber(cell string) (float64, bool) {
+	cell = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(cell), "%"))
+	v, err := strconv.ParseFloat(strings.ReplaceAll(cell, ",", "."), 64)
+	return v, err == nil
+}
+
+// Сравнение двух загрузок: кто появился в отчете, кто из него пропал и у
+// кого изменилось значение
+func compareReports(name string, prev, cur historyEntry) *Report {
+	before, prevOrder := reportRowFacts(prev.Report)
+	after, order := reportRowFacts(cur.Report)
+
+	columns := []string{"Запись", "Было", "Стало"}
+	added := ReportSection{Title: "🔴 Появились в отчете:", Columns: columns, Style: listNumbered}
+	increased := ReportSection{Title: "📈 Значение выросло:", Columns: columns, Style: listNumbered}
+	decreased := ReportSection{Title: "📉 Значение снизилось:", Columns: columns, Style: listNumbered}
+	for _, key := range order {
+		a := after[key]
+		b, existed := before[key]
+		switch {
+		case !existed:
+			added.Rows = append(added.Rows, ReportRow{
+				Cells: []string{a.label, "", formatFactValue(a)},
+				Text:  withFactValue(a.label, a),
+			})
+		case a.hasValue && b.hasValue && a.value != b.value:
+			row := ReportRow{
+				Cells: []string{a.label, formatFactValue(b), formatFactValue(a)},
+				Text:  fmt.Sprintf("%s: %s → %s", a.label, formatFactValue(b), formatFactValue(a)),
+			}
+			if a.value > b.value {
+				increased.Rows = append(increased.Rows, row)
+			} else {
+				decreased.Rows = append(decreased.Rows, row)
+			}
+		}
+	}
+	removed := ReportSection{Title: "🟢 Больше не в отчете:", Columns: columns, Style: listNumbered}
+	for _, key := range prevOrder {
+		if _, ok := after[key]; !ok {
+			b := before[key]
+			removed.Rows = append(removed.Rows, ReportRow{
+				Cells: []string{b.label, formatFactValue(b), ""},
+				Text:  withFactValue(b.label, b),
+			})
+		}
+	}
+
+	report := &Report{
+		Name:  "Сравнение — " + name,
+		Title: "📊 ИЗМЕНЕНИЯ: " + strings.ToUpper(name),
+		Intro: fmt.Sprintf("Загрузка от %s по сравнению с загрузкой от %s", cur.Time.Format("02.01.2006 15:04"), prev.Time.Format("02.01.2006 15:04")),
+	}
+	for _, s := range []ReportSection{added, removed, decreased, increased} {
+		if len(s.Rows) > 0 {
+			report.Sections = append(report.Sections, s)
+		}
+	}
+	if len(report.Sections) == 0 {
+		report.Summary = "Изменений нет"
+	}
+	return report
+}
+
+func formatFactValue(f rowFacts) string {
+	if !f.hasValue {
+		return ""
+	}
+	return strconv.FormatFloat(f.value, 'f', -1, 64)
+}
+
+func withFactValue(label string, f rowFacts) string {
+	if !f.hasValue {
+		return label
+	}
+	return fmt.Sprintf("%s (%s)", label, formatFactValue(f))
+}
+
+// Команда /compare: сравнение двух последних загрузок. Если в чате есть
+// история по нескольким типам отчетов, тип выбирается кнопкой
+func handleCompareCommand(bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	var kinds []Processor
+	if history != nil {
+		kinds = history.kinds(chatID, 2)
+	}
+	switch len(kinds) {
+	case 0:
+		bot.Send(tgbotapi.NewMessage(chatID, "Для сравнения нужно загрузить хотя бы два отчета одного типа"))
+		return
+	case 1:
+		sendComparison(bot, chatID, kinds[0])
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, p := range kinds {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(p.Name(), "compare_"+p.CallbackID()),
+		))
+	}
+	reply := tgbotapi.NewMessage(chatID, "Какой отчет сравнить с предыдущей загрузкой?")
+	reply.ReplyMarkup = tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+	bot.Send(reply)
+}
+
+func handleCompareCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	processor := processorByCallback(strings.TrimPrefix(callback.Data, "compare_"))
+	if processor == nil {
+		bot.Request(tgbotapi.NewCallback(callback.ID, "Неизвестный тип отчета"))
+		return
+	}
+	bot.Request(tgbotapi.NewCallback(callback.ID, processor.Name()))
+	sendComparison(bot, callback.Message.Chat.ID, processor)
+}
+
+func sendComparison(bot *tgbotapi.BotAPI, chatID int64, processor Processor) {
+	entries := history.last(chatID, processor.CallbackID(), 2)
+	if len(entries) < 2 {
+		bot.Send(tgbotapi.NewMessage(chatID, "Для сравнения нужно загрузить хотя бы два отчета одного типа"))
+		return
+	}
+	sendReport(bot, chatID, compareReports(processor.Name(), entries[0], entries[1]))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Журнал программы: уровень задается переменной log_level (debug, info,
+// warn, error), формат — log_format (text или json для сборщиков логов).
+// Сообщения пакета log, в том числе из tgbotapi, тоже идут через slog
+func setupLogging() {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(os.Getenv("log_level"))); err != nil {
+		level = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler = slog.NewTextHandler(os.Stderr, opts)
+	if strings.EqualFold(os.Getenv("log_format"), "json") {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(handler))
+}
+
+// Вид обновления для журнала
+func updateKind(update tgbotapi.Update) string {
+	switch {
+	case update.Message != nil && update.Message.IsCommand():
+		return "command"
+	case update.Message != nil && update.Message.Document != nil:
+		return "document"
+	case update.Message != nil:
+		return "message"
+	case update.CallbackQuery != nil:
+		return "callback"
+	}
+	return "other"
+}
+
+// Строка журнала для каждого обновления: кто, откуда, что прислал и сколько
+// заняла обработка. Подробности об обработке файлов пишет recordFileOutcome
+func logUpdate(update tgbotapi.Update, started time.Time, allowed bool) {
+	attrs := []any{"update_id", update.UpdateID, "kind", updateKind(update)}
+	if chat := update.FromChat(); chat != nil {
+		attrs = append(attrs, "chat_id", chat.ID)
+	}
+	if user := update.SentFrom(); user != nil {
+		attrs = append(attrs, "user_id", user.ID, "user", userLabel(user))
+	}
+	switch {
+	case update.Message != nil && update.Message.IsCommand():
+		attrs = append(attrs, "command", update.Message.Command())
+	case update.Message != nil && update.Message.Document != nil:
+		attrs = append(attrs, "file", update.Message.Document.FileName)
+	case update.CallbackQuery != nil:
+		attrs = append(attrs, "data", update.CallbackQuery.Data)
+	}
+	attrs = append(attrs, "duration", time.Since(started))
+	if !allowed {
+		slog.Warn("Обновление отклонено", attrs...)
+		return
+	}
+	slog.Info("Обновление обработано", attrs...)
+}
@@ -1,65 +1,157 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"path"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	godotenv "github.com/joho/godotenv"
-	excelize "github.com/xuri/excelize/v2"
 )
 
 // Глобальные переменные
 var bot *tgbotapi.BotAPI
-var textError string
-var strbuild strings.Builder
 
-// Карта для хранения режима обработки по chatID
-var userMode = make(map[int64]string)
-
-// функции для оптимизации
-func errors(err error, textError string) {
-	if err != nil {
-		fmt.Println(err)
-		log.Fatal(textError)
+// Проблемы настройки, найденные при запуске: бот сообщает их все сразу
+// и завершается, а не останавливается на первой
+func exitWithProblems(problems []string) {
+	fmt.Fprintln(os.Stderr, "Бот не запущен, проверьте настройки:")
+	for _, p := range problems {
+		fmt.Fprintln(os.Stderr, " -", p)
 	}
+	os.Exit(1)
 }
+
 func main() {
-	// Загружаем переменные окружения
-	err := godotenv.Load(".env")
-	textError = ".env не найден"
-	errors(err, textError)
-
-	bot, err = tgbotapi.NewBotAPI(os.Getenv("token_telegram_bot"))
-	textError = "Не удалось инициализировать api"
-	errors(err, textError)
-
-	// Настройка и получение обновлений
-	updateConf := tgbotapi.NewUpdate(0)
-	updateConf.Timeout = 30
-	updates := bot.GetUpdatesChan(updateConf)
-
-	// Обработка обновлений
-	for update := range updates {
-		strbuild.Reset()
-		if update.Message != nil {
-			if update.Message.IsCommand() {
-				handleCommand(bot, update.Message)
-			} else if update.Message.Document != nil {
-				handleDocument(bot, update.Message)
-			} else {
-				bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, "Пожалуйста, отправьте Excel файл или используйте /start для выбора режима."))
+	// С аргументами — обработка файлов из командной строки, без токена и Telegram
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
+	// Загружаем переменные окружения. Файл .env не обязателен: переменные
+	// могут быть заданы окружением, например в systemd или Docker
+	var problems []string
+	check := func(what string, err error) {
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", what, err))
+		}
+	}
+	if err := godotenv.Load(".env"); !os.IsNotExist(err) {
+		check("Файл .env", err)
+	}
+	setupLogging()
+	if os.Getenv("token_telegram_bot") == "" {
+		problems = append(problems, "Не задан токен бота: укажите token_telegram_bot в .env или в переменных окружения")
+	}
+
+	// Загружаем сохраненные режимы и настройки чатов
+	check("Настройки чатов", loadChatSettings(newJSONFileStorage(storagePath())))
+	check("История отчетов", loadReportHistory(historyPath()))
+	check("Список доступа", loadAccessList(accessPath()))
+	check("Журнал аудита", openAuditLog(auditPath()))
+	webhook, err := loadWebhookConfig()
+	check("Вебхук", err)
+	if len(problems) > 0 {
+		exitWithProblems(problems)
+	}
+
+	// Метрики для Prometheus, если задан адрес сервера
+	stopMetrics := func() {}
+	if addr := metricsListen(); addr != "" {
+		if stopMetrics, err = serveMetrics(addr); err != nil {
+			exitWithProblems([]string{fmt.Sprintf("Не удалось запустить сервер метрик: %v", err)})
+		}
+	}
+
+	bot, err = tgbotapi.NewBotAPIWithClient(os.Getenv("token_telegram_bot"), telegramAPIEndpoint(), telegramHTTP)
+	if err != nil {
+		exitWithProblems([]string{fmt.Sprintf("Не удалось подключиться к Telegram: %v", err)})
+	}
+
+	// Получение обновлений: через вебхук, если он настроен, иначе long polling
+	var updates tgbotapi.UpdatesChannel
+	stopUpdates := bot.StopReceivingUpdates
+	if webhook != nil {
+		updates, stopUpdates, err = listenWebhook(bot, webhook)
+		if err != nil {
+			exitWithProblems([]string{fmt.Sprintf("Не удалось запустить вебхук: %v", err)})
+		}
+	} else {
+		// Пока установлен вебхук, Telegram не отдает обновления через getUpdates
+		if _, err := bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+			slog.Warn("Не удалось удалить вебхук", "err", err)
+		}
+		updateConf := tgbotapi.NewUpdate(0)
+		updateConf.Timeout = 30
+		updates = bot.GetUpdatesChan(updateConf)
+	}
+
+	// Остановка по Ctrl+C или SIGTERM: сначала перестаем получать обновления,
+	// затем дожидаемся обработки уже принятых файлов
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Плановая рассылка отчетов подписанным чатам
+	go runScheduler(ctx, bot)
+
+	// Обработка обновлений пулом обработчиков
+	workers = newUpdatePool(workerCount(), func(update tgbotapi.Update) {
+		handleUpdate(bot, update)
+	})
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case update, ok := <-updates:
+			if !ok {
+				break loop
 			}
-		} else if update.CallbackQuery != nil {
-			handleCallback(bot, update.CallbackQuery)
+			workers.submit(update)
 		}
 	}
+	slog.Info("Остановка бота, завершаю обработку принятых файлов")
+	stopUpdates()
+	workers.stop()
+	finishMediaGroups(bot)
+	stopMetrics()
+	slog.Info("Бот остановлен")
+}
+
+func handleUpdate(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
+	started := time.Now()
+	role, ok := authorizeUpdate(bot, update)
+	defer logUpdate(update, started, ok)
+	metrics.countUpdate(update)
+	if !ok {
+		return
+	}
+	if update.Message != nil {
+		if update.Message.IsCommand() {
+			cancelSettingInput(update.Message.Chat.ID)
+			handleCommand(bot, update.Message)
+		} else if update.Message.Document != nil {
+			handleDocument(bot, update.Message)
+		} else if role >= commandRole("settings") && handleSettingInput(bot, update.Message) {
+			return
+		} else {
+			bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, "Пожалуйста, отправьте Excel файл или используйте /start для выбора режима."))
+		}
+	} else if update.CallbackQuery != nil {
+		handleCallback(bot, update.CallbackQuery)
+	}
 }
 
 func handleCommand(bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
@@ -67,9 +159,27 @@ func handleCommand(bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
 	case "start":
 		bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "Здравстуйте, это бот по обработке отчетов\nвоспользуйтесь /help для того чтобы узнать больше"))
 	case "help":
-		bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "Отправьте XLSX/XLS файл, и я подготовлю нужный отчет.\nИспользуйте /setmode, чтобы выбрать режим обработки"))
+		bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "Отправьте XLSX/XLS файл, и я подготовлю нужный отчет.\nМожно отправить сразу несколько файлов или ZIP-архив — придет общий отчет по всем файлам\nИспользуйте /setmode, чтобы выбрать режим обработки\nИспользуйте /settings, чтобы изменить пороги отчетов\nИспользуйте /format, чтобы получать отчеты текстом или файлом (Excel, PDF, CSV, JSON)\nИспользуйте /sheets, чтобы выбирать листы в книгах с несколькими листами\nИспользуйте /columns, чтобы указать, как в ваших файлах называются колонки\nИспользуйте /compare, чтобы увидеть изменения с предыдущей загрузки отчета\nИспользуйте /schedule, чтобы получать сводку по расписанию\nИспользуйте /dashboard, чтобы увидеть сводную панель по преподавателям и студентам\nАдминистраторам: /users — управление доступом к боту, /audit — журнал обработанных файлов"))
 	case "setmode":
 		sendModeSelection(bot, msg.Chat.ID)
+	case "settings":
+		sendSettings(bot, msg.Chat.ID)
+	case "format", "export":
+		handleFormatCommand(bot, msg)
+	case "sheets":
+		handleSheetsCommand(bot, msg)
+	case "columns":
+		handleColumnsCommand(bot, msg)
+	case "compare":
+		handleCompareCommand(bot, msg)
+	case "schedule":
+		handleScheduleCommand(bot, msg)
+	case "dashboard":
+		handleDashboardCommand(bot, msg)
+	case "users":
+		handleUsersCommand(bot, msg)
+	case "audit":
+		handleAuditCommand(bot, msg)
 	default:
 		bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "Неизвестная команда. Используйте /start или /help"))
 	}
@@ -77,46 +187,58 @@ func handleCommand(bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
 
 func sendModeSelection(bot *tgbotapi.BotAPI, chatID int64) {
 	msg := tgbotapi.NewMessage(chatID, "Выберите режим обработки:")
-	keyboard := tgbotapi.InlineKeyboardMarkup{
-		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
-			{
-				tgbotapi.NewInlineKeyboardButtonData("Расписание групп", "mode_schedule"),
-				tgbotapi.NewInlineKeyboardButtonData("Темы уроков", "mode_lessons"),
-			},
-			{
-				tgbotapi.NewInlineKeyboardButtonData("Студенты", "mode_students"),
-				tgbotapi.NewInlineKeyboardButtonData("Посещаемость", "mode_attendance"),
-			},
-			{
-				tgbotapi.NewInlineKeyboardButtonData("Проверенные ДЗ", "mode_checked_homework"),
-				tgbotapi.NewInlineKeyboardButtonData("Сданные ДЗ", "mode_submitted_homework"),
-			},
-		},
-	}
-	msg.ReplyMarkup = keyboard
+	// Кнопки по две в ряд в порядке регистрации обработчиков
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, p := range processors {
+		button := tgbotapi.NewInlineKeyboardButtonData(p.Label(), p.CallbackID())
+		if i%2 == 0 {
+			rows = append(rows, []tgbotapi.InlineKeyboardButton{button})
+		} else {
+			rows[len(rows)-1] = append(rows[len(rows)-1], button)
+		}
+	}
+	msg.ReplyMarkup = tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
 	bot.Send(msg)
 }
 
 func handleCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
 	chatID := callback.Message.Chat.ID
-	data := callback.Data
-
-	switch data {
-	case "mode_schedule":
-		userMode[chatID] = "schedule"
-	case "mode_lessons":
-		userMode[chatID] = "lessons"
-	case "mode_students":
-		userMode[chatID] = "students"
-	case "mode_attendance":
-		userMode[chatID] = "attendance"
-	case "mode_checked_homework":
-		userMode[chatID] = "checked_homework"
-	case "mode_submitted_homework":
-		userMode[chatID] = "submitted_homework"
-	}
-
-	text := "Режим выбран: " + strings.Title(strings.ReplaceAll(data[5:], "_", " "))
+
+	if strings.HasPrefix(callback.Data, "settings_") {
+		handleSettingsCallback(bot, callback)
+		return
+	}
+	if strings.HasPrefix(callback.Data, "format_") {
+		handleFormatCallback(bot, callback)
+		return
+	}
+	if strings.HasPrefix(callback.Data, "sheets_") {
+		handleSheetsCallback(bot, callback)
+		return
+	}
+	if strings.HasPrefix(callback.Data, "sheetsel_") {
+		handleSheetSelectCallback(bot, callback)
+		return
+	}
+	if strings.HasPrefix(callback.Data, "compare_") {
+		handleCompareCallback(bot, callback)
+		return
+	}
+	if strings.HasPrefix(callback.Data, "detect_") {
+		handleDetectCallback(bot, callback)
+		return
+	}
+
+	processor := processorByCallback(callback.Data)
+	if processor == nil {
+		bot.Request(tgbotapi.NewCallback(callback.ID, "Неизвестный режим"))
+		return
+	}
+	if err := setUserMode(chatID, processor.CallbackID()); err != nil {
+		slog.Error("Не удалось сохранить режим чата", "chat_id", chatID, "err", err)
+	}
+
+	text := "Режим выбран: " + processor.Name()
 	bot.Request(tgbotapi.NewCallback(callback.ID, text))
 	bot.Send(tgbotapi.NewMessage(chatID, "Режим обработки установлен. Теперь отправьте файл для обработки."))
 }
@@ -124,100 +246,154 @@ func handleCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
 func handleDocument(bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
 	chatID := msg.Chat.ID
 	filename := msg.Document.FileName
+	doc := pendingFile{messageID: msg.MessageID, fileID: msg.Document.FileID, filename: filename}
+	if msg.From != nil {
+		doc.userID, doc.user = msg.From.ID, userLabel(msg.From)
+	}
 
-	if !(strings.HasSuffix(filename, ".xlsx") || strings.HasSuffix(filename, ".xls")) {
-		bot.Send(tgbotapi.NewMessage(chatID, "Пожалуйста, отправьте файл в формате Excel (.xlsx или .xls)"))
+	if !isWorkbookName(filename) && !isArchiveName(filename) {
+		recordFileOutcome(doc.auditEntry(chatID, outcomeNotAllowed), time.Now())
+		bot.Send(tgbotapi.NewMessage(chatID, "Пожалуйста, отправьте файл в формате Excel (.xlsx или .xls) или архив .zip с такими файлами"))
 		return
 	}
-
-	sentMsg, _ := bot.Send(tgbotapi.NewMessage(chatID, "⏳ Обрабатываю файл..."))
-
-	file, err := bot.GetFile(tgbotapi.FileConfig{FileID: msg.Document.FileID})
-	if err != nil {
-		bot.Send(tgbotapi.NewMessage(chatID, "Ошибка при получении файла"))
+	// Размер известен заранее, большой файл отклоняется без скачивания
+	if limit := maxFileSize(); int64(msg.Document.FileSize) > limit {
+		entry := doc.auditEntry(chatID, outcomeTooLarge)
+		entry.err = &fileTooLargeError{size: int64(msg.Document.FileSize), limit: limit}
+		recordFileOutcome(entry, time.Now())
+		bot.Send(tgbotapi.NewMessage(chatID, userMessage(entry.err)))
 		return
 	}
-	url := file.Link(bot.Token)
 
-	localPath := fmt.Sprintf("temp_%d_%s", msg.MessageID, filename)
-	defer os.Remove(localPath)
-	if err := downloadFile(url, localPath); err != nil {
-		bot.Send(tgbotapi.NewMessage(chatID, "Ошибка при скачивании файла"))
-		return
+	switch {
+	case msg.MediaGroupID != "":
+		collectMediaGroup(bot, chatID, msg.MediaGroupID, doc)
+	case isArchiveName(filename):
+		processBatch(bot, chatID, []pendingFile{doc})
+	default:
+		processDocument(bot, chatID, doc)
 	}
+}
 
-	// Попытка определить тип файла автоматически
-	category := determineFileType(localPath)
+// Скачивание и обработка файла. Если обработчик еще не выбран, он берется из
+// режима чата или определяется по содержимому файла
+func processDocument(bot *tgbotapi.BotAPI, chatID int64, doc pendingFile) {
+	started := time.Now()
+	entry := doc.auditEntry(chatID, outcomeError)
+	defer func() { recordFileOutcome(entry, started) }()
 
-	// Проверка режима
-	mode, boolMode := userMode[chatID]
-	var res string
-	var errProcess error
+	sentMsg, _ := bot.Send(tgbotapi.NewMessage(chatID, "⏳ Обрабатываю файл..."))
 
-	if boolMode {
-		switch mode {
-		case "schedule", "lessons", "students", "attendance", "checked_homework", "submitted_homework":
-		default:
+	// ID сообщения уникален только внутри чата, а чаты обрабатываются параллельно
+	localPath := fmt.Sprintf("temp_%d_%d_%s", chatID, doc.messageID, path.Base(doc.filename))
+	defer os.Remove(localPath)
+	if err := fetchFile(bot, doc.fileID, localPath); err != nil {
+		entry.Outcome, entry.err = outcomeDownload, err
+		text := "Ошибка при скачивании файла"
+		var tooLarge *fileTooLargeError
+		if errors.As(err, &tooLarge) {
+			entry.Outcome, text = outcomeTooLarge, userMessage(err)
+		}
+		bot.Send(tgbotapi.NewMessage(chatID, text))
+		return
+	}
+
+	// Обработка по выбранному режиму, иначе по автоматически определенному типу файла
+	processor := doc.processor
+	mode, boolMode := getUserMode(chatID)
+	switch {
+	case processor != nil:
+	case boolMode:
+		processor = processorByCallback(mode)
+		if processor == nil {
+			entry.Error = "некорректный режим " + mode
 			bot.Send(tgbotapi.NewMessage(chatID, "Некорректный режим обработки. Используйте /start для выбора режима."))
 			return
 		}
-	} else {
-		if category == "" {
+	default:
+		var choices []fileTypeCandidate
+		processor, choices = pickFileType(determineFileType(localPath))
+		switch {
+		case processor != nil:
+			entry.Category = processor.CallbackID()
+		case len(choices) > 0:
+			entry.Category, entry.Outcome = choices[0].processor.CallbackID(), outcomeAskType
+		default:
+			entry.Outcome = outcomeUnknown
+		}
+		if len(choices) > 0 {
+			bot.Send(tgbotapi.NewDeleteMessage(chatID, sentMsg.MessageID))
+			askFileType(bot, chatID, doc, choices)
+			return
+		}
+		if processor == nil {
 			bot.Send(tgbotapi.NewMessage(chatID, "Не удалось определить тип файла. Пожалуйста, убедитесь, что выбран правильный файл."))
 			return
 		}
 	}
 
-	// Обработка по режиму или по определенному типу файла
-	if boolMode {
-		switch mode {
-		case "schedule":
-			res, errProcess = processSchedule(localPath)
-		case "lessons":
-			res, errProcess = processLessonTopics(localPath)
-		case "students":
-			res, errProcess = processStudents(localPath)
-		case "attendance":
-			res, errProcess = processAttendance(localPath)
-		case "checked_homework":
-			res, errProcess = processCheckedHomework(localPath)
-		case "submitted_homework":
-			res, errProcess = processSubmittedHomework(localPath)
-		}
-	} else {
-		switch category {
-		case "Расписание групп":
-			res, errProcess = processSchedule(localPath)
-		case "Темы уроков":
-			res, errProcess = processLessonTopics(localPath)
-		case "Отчет по студентам":
-			res, errProcess = processStudents(localPath)
-		case "Посещаемость по преподавателям":
-			res, errProcess = processAttendance(localPath)
-		case "Отчет по проверенным ДЗ":
-			res, errProcess = processCheckedHomework(localPath)
-		case "Отчет по сданным ДЗ":
-			res, errProcess = processSubmittedHomework(localPath)
-		default:
-			bot.Send(tgbotapi.NewMessage(chatID, "Обработка этого типа файла не реализована или не распознана."))
+	// Для книги с несколькими листами пользователь может выбрать нужные
+	if doc.sheets == nil && chatSheetMode(chatID) == sheetModeAsk {
+		if names := workbookSheetNames(localPath); len(names) > 1 {
+			bot.Send(tgbotapi.NewDeleteMessage(chatID, sentMsg.MessageID))
+			doc.processor = processor
+			entry.Processor, entry.Outcome = processor.CallbackID(), outcomeAskSheets
+			askSheets(bot, chatID, doc, names)
 			return
 		}
 	}
 
+	opts := chatProcessOptions(chatID)
+	opts.sheets = doc.sheets
+	entry.Processor = processor.CallbackID()
+	progress := startProgress(bot, chatID, sentMsg.MessageID)
+	opts.progress = progress.update
+	report, errProcess := processor.Process(localPath, opts)
+	progress.finish()
 	if errProcess != nil {
-		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Ошибка при обработке файла: %v", errProcess)))
+		entry.err = errProcess
+		bot.Send(tgbotapi.NewDeleteMessage(chatID, sentMsg.MessageID))
+		bot.Send(tgbotapi.NewMessage(chatID, userMessage(errProcess)))
 		return
 	}
 
-	parts := splitMessage(res, 4000)
 	bot.Send(tgbotapi.NewDeleteMessage(chatID, sentMsg.MessageID))
-	for _, part := range parts {
-		bot.Send(tgbotapi.NewMessage(chatID, part))
+	entry.Outcome = outcomeOK
+	report.Name = processor.Name()
+	recordReport(chatID, processor.CallbackID(), report)
+	recordDashboardGrades(chatID, processor, localPath, opts)
+	sendReport(bot, chatID, report)
+}
+
+// Скачивание файла Telegram по FileID
+func fetchFile(bot *tgbotapi.BotAPI, fileID, localPath string) error {
+	file, err := bot.GetFile(tgbotapi.FileConfig{FileID: fileID})
+	if err != nil {
+		return err
+	}
+	return downloadFile(file.Link(bot.Token), localPath, maxFileSize())
+}
+
+// Наибольший размер файла по умолчанию: больше Bot API все равно не отдает
+const defaultMaxFileSize = 20 << 20
+
+// Наибольший размер отправленного файла в байтах
+func maxFileSize() int64 {
+	value, err := strconv.Atoi(os.Getenv("max_file_size_mb"))
+	if err != nil || value <= 0 {
+		return defaultMaxFileSize
 	}
+	return int64(value) << 20
 }
 
-func downloadFile(url, path string) error {
-	responce, err := http.Get(url)
+// Скачивание файла не больше limit байт: размер в сообщении может
+// отсутствовать или не совпадать с настоящим
+func downloadFile(url, path string, limit int64) error {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	responce, err := telegramHTTP.Do(request)
 	if err != nil {
 		return err
 	}
@@ -225,146 +401,176 @@ func downloadFile(url, path string) error {
 	if responce.StatusCode != http.StatusOK {
 		return fmt.Errorf("HTTP статус %d", responce.StatusCode)
 	}
+	if responce.ContentLength > limit {
+		return &fileTooLargeError{size: responce.ContentLength, limit: limit}
+	}
 	out, err := os.Create(path)
 	if err != nil {
 		return err
 	}
 	defer out.Close()
-	_, err = io.Copy(out, responce.Body)
-	return err
-}
-
-// Функция определения типа файла по содержимому
-func determineFileType(filepath string) string {
-	file, err := excelize.OpenFile(filepath)
-	if err != nil {
-		return ""
-	}
-	defer file.Close()
-	sheets := file.GetSheetList()
-	if len(sheets) == 0 {
-		return ""
-	}
-	rows, err := file.GetRows(sheets[0])
-	if err != nil || len(rows) == 0 {
-		return ""
-	}
-	header := rows[0]
-	txt := strings.ToLower(strings.Join(header, " "))
-
-	if strings.Contains(txt, "группа") && strings.Contains(txt, "время") && strings.Contains(txt, "пара") {
-		return "Расписание групп"
+	size, err := io.Copy(out, io.LimitReader(responce.Body, limit+1))
+	metrics.observeDownload(size)
+	if err == nil && size > limit {
+		return &fileTooLargeError{limit: limit}
 	}
-	if strings.Contains(txt, "урок") || strings.Contains(txt, "тема") || strings.Contains(txt, "тема урока") {
-		return "Темы уроков"
-	}
-	if strings.Contains(txt, "fio") || (strings.Contains(txt, "homework") && strings.Contains(txt, "classroom")) {
-		return "Отчет по студентам"
-	}
-	if strings.Contains(txt, "фио преподавателя") && strings.Contains(txt, "средняя посещаемость") {
-		return "Посещаемость по преподавателям"
-	}
-	if strings.Contains(txt, "форма обучения") && strings.Contains(txt, "фио преподавателя") ||
-		(strings.Contains(txt, "месяц") || strings.Contains(txt, "неделя")) || strings.Contains(txt, "день") || strings.Contains(txt, "проверено") {
-		return "Отчет по проверенным ДЗ"
-	}
-	if strings.Contains(txt, "fio") && (strings.Contains(txt, "percentage homework") || strings.Contains(txt, "домашнее")) {
-		return "Отчет по сданным ДЗ"
-	}
-	return ""
+	return err
 }
 
 // 1. Расписание групп
-func processSchedule(filepath string) (string, error) {
-	file, err := excelize.OpenFile(filepath)
-	if err != nil {
-		return "", err
-	}
-	defer file.Close()
-
-	rows, err := file.GetRows(file.GetSheetName(0))
-	if err != nil || len(rows) < 2 {
-		return "Нет данных в файле", nil
-	}
-	header := rows[0]
-	groupIndx, pairIndx, timeIndx := -1, -1, -1
+func processSchedule(rows *sheetReader, opts processOptions) (*Report, error) {
+	head := rows.head()
+	if len(head) < 2 {
+		return nil, errEmptySheet
+	}
+	header, missing := locateHeader(head, []headerColumn{
+		{field: "group", required: true},
+		{field: "pair", required: true},
+		{field: "time", required: true},
+	}, opts.columns)
+	if missing != nil {
+		return nil, &missingColumnsError{fields: missing}
+	}
+	groupIndx, pairIndx := header.index("group"), header.index("pair")
+	// В выгрузке расписания дисциплины записаны в колонках дней недели,
+	// а в колонке «Пара» — номер пары. Если дней в заголовке нет, дисциплина
+	// берется из колонки «Пара»
+	dayCols := scheduleDayColumns(head[:header.dataStart])
 
-	for i, col := range header {
-		colLower := strings.ToLower(col)
-		if strings.Contains(colLower, "группа") {
-			groupIndx = i
-		} else if strings.Contains(colLower, "пара") {
-			if pairIndx == -1 {
-				pairIndx = i
-			}
-		} else if strings.Contains(colLower, "время") {
-			if timeIndx == -1 {
-				timeIndx = i
-			}
+	groupStats := make(map[string]map[string]int)
+	count := func(group, cell string) {
+		subject := scheduleSubject(cell)
+		if subject == "" {
+			return
 		}
+		if _, ok := groupStats[group]; !ok {
+			groupStats[group] = make(map[string]int)
+		}
+		groupStats[group][subject]++
 	}
 
-	if groupIndx == -1 || pairIndx == -1 || timeIndx == -1 {
-		return "Не удалось найти колонки 'Группа' или 'Пара'", nil
-	}
-
-	groupStats := make(map[string]map[string]int)
-
-	for _, row := range rows[1:] {
-		if len(row) <= max(groupIndx, pairIndx) {
+	rows.skip(header.dataStart)
+	for rows.next() {
+		row := rows.row()
+		if len(row) <= groupIndx {
 			continue
 		}
 		group := strings.TrimSpace(row[groupIndx])
-		subject := strings.TrimSpace(row[pairIndx])
-		if group == "" || subject == "" {
+		if group == "" {
 			continue
 		}
-		if _, ok := groupStats[group]; !ok {
-			groupStats[group] = make(map[string]int)
+		if len(dayCols) == 0 {
+			if len(row) > pairIndx {
+				count(group, row[pairIndx])
+			}
+			continue
+		}
+		for _, c := range dayCols {
+			if c < len(row) {
+				count(group, row[c])
+			}
 		}
-		groupStats[group][subject]++
 	}
 
-	strbuild.WriteString("📅 ОТЧЕТ ПО РАСПИСАНИЮ ГРУПП\n")
-	strbuild.WriteString("Количество пар по дисциплинам:\n\n")
-	for group, subjects := range groupStats {
-		strbuild.WriteString(fmt.Sprintf("Группа: %s\n", group))
-		for subj, count := range subjects {
-			strbuild.WriteString(fmt.Sprintf("  %s: %d пар\n", subj, count))
-		}
-		strbuild.WriteString("\n")
+	report := &Report{
+		Title: "📅 ОТЧЕТ ПО РАСПИСАНИЮ ГРУПП",
+		Intro: "Количество пар по дисциплинам:",
+	}
+	groups := make([]string, 0, len(groupStats))
+	for group := range groupStats {
+		groups = append(groups, group)
 	}
-	return strbuild.String(), nil
+	sort.Strings(groups)
+	for _, group := range groups {
+		subjects := groupStats[group]
+		names := make([]string, 0, len(subjects))
+		for subj := range subjects {
+			names = append(names, subj)
+		}
+		// Сначала дисциплины с наибольшим числом пар
+		sort.Slice(names, func(i, j int) bool {
+			if subjects[names[i]] != subjects[names[j]] {
+				return subjects[names[i]] > subjects[names[j]]
+			}
+			return names[i] < names[j]
+		})
+		section := ReportSection{
+			Title:   fmt.Sprintf("Группа: %s", group),
+			Columns: []string{"Группа", "Дисциплина", "Количество пар"},
+			Style:   listIndented,
+		}
+		for _, subj := range names {
+			section.Rows = append(section.Rows, ReportRow{
+				Cells: []string{group, subj, strconv.Itoa(subjects[subj])},
+				Text:  fmt.Sprintf("%s: %d пар", subj, subjects[subj]),
+			})
+		}
+		report.Sections = append(report.Sections, section)
+	}
+	return report, nil
 }
 
-// 2. Темы уроков
-func processLessonTopics(filepath string) (string, error) {
-	file, err := excelize.OpenFile(filepath)
-	if err != nil {
-		return "", err
+// Дни недели в заголовке расписания: «Понедельник. 15.12.2025»
+var scheduleWeekdays = []string{"понедельник", "вторник", "среда", "четверг", "пятница", "суббота", "воскресенье"}
+
+// Колонки дней недели в строках заголовка
+func scheduleDayColumns(header [][]string) []int {
+	var cols []int
+	seen := make(map[int]bool)
+	for _, row := range header {
+		for c, cell := range row {
+			name := normalizeColumnName(cell)
+			for _, day := range scheduleWeekdays {
+				if strings.HasPrefix(name, day) && !seen[c] {
+					cols = append(cols, c)
+					seen[c] = true
+				}
+			}
+		}
 	}
-	defer file.Close()
+	sort.Ints(cols)
+	return cols
+}
 
-	rows, err := file.GetRows(file.GetSheetName(0))
-	if err != nil || len(rows) == 0 {
-		return "Нет данных в файле", nil
+// Дисциплина из ячейки расписания. В выгрузке ячейка пары состоит из строк
+// «Предмет: ...», «Группа: ...», «Препод.: ...»; номер пары дисциплиной не считается
+func scheduleSubject(cell string) string {
+	cell = strings.TrimSpace(cell)
+	if cell == "" {
+		return ""
 	}
-
-	topicCol := -1
-	for i, col := range rows[0] {
-		if strings.Contains(strings.ToLower(col), "тема урока") {
-			topicCol = i
-			break
+	if _, err := strconv.Atoi(cell); err == nil {
+		return ""
+	}
+	for _, line := range strings.Split(cell, "\n") {
+		if label, value, ok := strings.Cut(line, ":"); ok && normalizeColumnName(label) == "предмет" {
+			return strings.TrimSpace(value)
 		}
 	}
-	if topicCol == -1 {
-		return "Не найдена колонка с темами уроков", nil
+	return strings.TrimSpace(strings.Split(cell, "\n")[0])
+}
+
+// 2. Темы уроков
+func processLessonTopics(rows *sheetReader, opts processOptions) (*Report, error) {
+	head := rows.head()
+	if len(head) == 0 {
+		return nil, errEmptySheet
+	}
+
+	header, missing := locateHeader(head, []headerColumn{
+		{field: "topic", required: true},
+	}, opts.columns)
+	if missing != nil {
+		return nil, &missingColumnsError{fields: missing}
 	}
+	topicCol := header.index("topic")
 
-	validTopics := []string{}
-	invalidTopics := []string{}
+	validTopics := ReportSection{Title: "✅ Темы в правильном формате:", Columns: []string{"Тема урока"}, Style: listBulleted}
+	invalidTopics := ReportSection{Title: "❌ Темы в НЕправильном формате:", Columns: []string{"Тема урока"}, Style: listBulleted}
 	pattern := regexp.MustCompile(`^Урок №\s*\d+.*Тема:`)
-	for _, row := range rows[1:] {
+	rows.skip(header.dataStart)
+	for rows.next() {
+		row := rows.row()
 		if len(row) <= topicCol {
 			continue
 		}
@@ -372,63 +578,51 @@ func processLessonTopics(filepath string) (string, error) {
 		if topic == "" {
 			continue
 		}
+		item := ReportRow{Cells: []string{topic}, Text: topic}
 		if pattern.MatchString(topic) {
-			validTopics = append(validTopics, topic)
-
+			validTopics.Rows = append(validTopics.Rows, item)
 		} else {
-			invalidTopics = append(invalidTopics, topic)
-
+			invalidTopics.Rows = append(invalidTopics.Rows, item)
 		}
 	}
 
-	strbuild.WriteString("📚 ОТЧЕТ ПО ТЕМАМ ЗАНЯТИЙ\n\n")
-	if len(validTopics) > 0 {
-		strbuild.WriteString("✅ Темы в правильном формате:\n")
-		for _, t := range validTopics {
-			strbuild.WriteString(fmt.Sprintf("• %s\n", t))
-		}
-		strbuild.WriteString("\n")
+	report := &Report{Title: "📚 ОТЧЕТ ПО ТЕМАМ ЗАНЯТИЙ"}
+	if len(validTopics.Rows) > 0 {
+		report.Sections = append(report.Sections, validTopics)
 	}
-	if len(invalidTopics) > 0 {
-		strbuild.WriteString("❌ Темы в НЕправильном формате:\n")
-		for _, t := range invalidTopics {
-			strbuild.WriteString(fmt.Sprintf("• %s\n", t))
-		}
-	} else if len(validTopics) == 0 {
-		strbuild.WriteString("Темы уроков не найдены")
+	if len(invalidTopics.Rows) > 0 {
+		report.Sections = append(report.Sections, invalidTopics)
+	} else if len(validTopics.Rows) == 0 {
+		report.Summary = "Темы уроков не найдены"
 	}
-	return strbuild.String(), nil
+	return report, nil
 }
 
 // 3. Студенты со слабым оцениванием
-func processStudents(filepath string) (string, error) {
-	file, err := excelize.OpenFile(filepath)
-	if err != nil {
-		return "", err
-	}
-	defer file.Close()
-
-	rows, err := file.GetRows(file.GetSheetName(0))
-	if err != nil || len(rows) < 2 {
-		return "Нет данных в файле", nil
-	}
-	header := rows[0]
-	fioIndx, homeworkIndx, classworkIndx := -1, -1, -1
-	for i, col := range header {
-		switch strings.ToLower(col) {
-		case "фио", "fio":
-			fioIndx = i
-		case "homework", "домашняя работа":
-			homeworkIndx = i
-		case "classwork", "классная работа":
-			classworkIndx = i
-		}
-	}
-	if fioIndx == -1 {
-		return "Не найдена колонка с ФИО студентов", nil
-	}
-	var problemStudents []string
-	for _, row := range rows[1:] {
+func processStudents(rows *sheetReader, opts processOptions) (*Report, error) {
+	head := rows.head()
+	if len(head) < 2 {
+		return nil, errEmptySheet
+	}
+	header, missing := locateHeader(head, []headerColumn{
+		{field: "fio", required: true},
+		{field: "homework"},
+		{field: "classwork"},
+	}, opts.columns)
+	if missing != nil {
+		return nil, &missingColumnsError{fields: missing}
+	}
+	fioIndx, homeworkIndx, classworkIndx := header.index("fio"), header.index("homework"), header.index("classwork")
+	homeworkThreshold := opts.threshold("student_homework")
+	classworkThreshold := opts.threshold("student_classwork")
+	problemStudents := ReportSection{
+		Title:   "Студенты, требующие внимания:",
+		Columns: []string{"ФИО", "Вид работы", "Оценка"},
+		Style:   listNumbered,
+	}
+	rows.skip(header.dataStart)
+	for rows.next() {
+		row := rows.row()
 		if len(row) <= max(fioIndx, homeworkIndx, classworkIndx) {
 			continue
 		}
@@ -437,57 +631,61 @@ func processStudents(filepath string) (string, error) {
 			continue
 		}
 		if homeworkIndx != -1 && len(row) > homeworkIndx {
-			if row[homeworkIndx] == "1" {
-				problemStudents = append(problemStudents, fmt.Sprintf("%s (домашняя: 1)", name))
-				continue
+			// Ноль означает, что оценок за домашние работы нет
+			gradeStr := strings.TrimSpace(row[homeworkIndx])
+			if grade, err := strconv.ParseFloat(gradeStr, 64); err == nil && grade > 0 && grade <= homeworkThreshold {
+				problemStudents.Rows = append(problemStudents.Rows, ReportRow{
+					Cells: []string{name, "домашняя", gradeStr},
+					Text:  fmt.Sprintf("%s (домашняя: %s)", name, gradeStr),
+				})
+				// В отчете студент указывается один раз; обе оценки нужны только сводной панели
+				if !opts.allGrades {
+					continue
+				}
 			}
 		}
 		if classworkIndx != -1 && len(row) > classworkIndx {
 			gradeStr := strings.TrimSpace(row[classworkIndx])
-			if grade, err := strconv.ParseFloat(gradeStr, 64); err == nil && grade < 3 {
-				problemStudents = append(problemStudents, fmt.Sprintf("%s (классная: %.1f)", name, grade))
+			if grade, err := strconv.ParseFloat(gradeStr, 64); err == nil && grade < classworkThreshold {
+				problemStudents.Rows = append(problemStudents.Rows, ReportRow{
+					Cells: []string{name, "классная", fmt.Sprintf("%.1f", grade)},
+					Text:  fmt.Sprintf("%s (классная: %.1f)", name, grade),
+				})
 			}
 		}
 	}
-	strbuild.WriteString("👨‍🎓 ОТЧЕТ ПО СТУДЕНТАМ\n\n")
-	if len(problemStudents) > 0 {
-		strbuild.WriteString("Студенты, требующие внимания:\n")
-		for i, s := range problemStudents {
-			strbuild.WriteString(fmt.Sprintf("%d. %s\n", i+1, s))
-		}
+	report := &Report{Title: "👨‍🎓 ОТЧЕТ ПО СТУДЕНТАМ"}
+	if len(problemStudents.Rows) > 0 {
+		report.Sections = append(report.Sections, problemStudents)
 	} else {
-		strbuild.WriteString("✅ Все студенты успешно справляются")
+		report.Summary = "✅ Все студенты успешно справляются"
 	}
-	return strbuild.String(), nil
+	return report, nil
 }
 
-// 4. Посещаемость преподавателей ниже 40%
-func processAttendance(filepath string) (string, error) {
-	file, err := excelize.OpenFile(filepath)
-	if err != nil {
-		return "", err
-	}
-	defer file.Close()
-
-	rows, err := file.GetRows(file.GetSheetName(0))
-	if err != nil || len(rows) < 2 {
-		return "Нет данных в файле", nil
-	}
-	header := rows[0]
-	teacherIndx, attendanceIndx := -1, -1
-	for i, col := range header {
-		switch strings.ToLower(col) {
-		case "фио преподавателя":
-			teacherIndx = i
-		case "средняя посещаемость":
-			attendanceIndx = i
-		}
-	}
-	if teacherIndx == -1 || attendanceIndx == -1 {
-		return "Не найдены необходимые колонки", nil
-	}
-	var lowAttendanceTeachers []string
-	for _, row := range rows[1:] {
+// 4. Посещаемость преподавателей ниже порога
+func processAttendance(rows *sheetReader, opts processOptions) (*Report, error) {
+	head := rows.head()
+	if len(head) < 2 {
+		return nil, errEmptySheet
+	}
+	header, missing := locateHeader(head, []headerColumn{
+		{field: "teacher", required: true},
+		{field: "attendance", required: true},
+	}, opts.columns)
+	if missing != nil {
+		return nil, &missingColumnsError{fields: missing}
+	}
+	teacherIndx, attendanceIndx := header.index("teacher"), header.index("attendance")
+	threshold := opts.threshold("teacher_attendance")
+	lowAttendanceTeachers := ReportSection{
+		Title:   fmt.Sprintf("Преподаватели с посещаемостью ниже %s%%:", formatThreshold(threshold)),
+		Columns: []string{"ФИО преподавателя", "Посещаемость, %"},
+		Style:   listNumbered,
+	}
+	rows.skip(header.dataStart)
+	for rows.next() {
+		row := rows.row()
 		if len(row) <= max(teacherIndx, attendanceIndx) {
 			continue
 		}
@@ -498,52 +696,49 @@ func processAttendance(filepath string) (string, error) {
 		}
 		attStr = strings.TrimSuffix(attStr, "%")
 		if att, err := strconv.ParseFloat(attStr, 64); err == nil {
-			if att < 40 {
-				lowAttendanceTeachers = append(lowAttendanceTeachers, fmt.Sprintf("%s (%.1f%%)", teacher, att))
+			if att < threshold {
+				lowAttendanceTeachers.Rows = append(lowAttendanceTeachers.Rows, ReportRow{
+					Cells: []string{teacher, fmt.Sprintf("%.1f", att)},
+					Text:  fmt.Sprintf("%s (%.1f%%)", teacher, att),
+				})
 			}
 		}
 	}
-	strbuild.WriteString("👨‍🏫 ОТЧЕТ ПО ПОСЕЩАЕМОСТИ ПРЕПОДАВАТЕЛЕЙ\n\n")
-	if len(lowAttendanceTeachers) > 0 {
-		strbuild.WriteString("Преподаватели с посещаемостью ниже 40%:\n")
-		for i, t := range lowAttendanceTeachers {
-			strbuild.WriteString(fmt.Sprintf("%d. %s\n", i+1, t))
-		}
+	report := &Report{Title: "👨‍🏫 ОТЧЕТ ПО ПОСЕЩАЕМОСТИ ПРЕПОДАВАТЕЛЕЙ"}
+	if len(lowAttendanceTeachers.Rows) > 0 {
+		report.Sections = append(report.Sections, lowAttendanceTeachers)
 	} else {
-		strbuild.WriteString("✅ У всех преподавателей посещаемость 40% и выше")
+		report.Summary = fmt.Sprintf("✅ У всех преподавателей посещаемость %s%% и выше", formatThreshold(threshold))
 	}
-	return strbuild.String(), nil
+	return report, nil
 }
 
 // 5. Проверка проверенных домашних
-func processCheckedHomework(filepath string) (string, error) {
-	file, err := excelize.OpenFile(filepath)
-	if err != nil {
-		return "", err
-	}
-	defer file.Close()
-
-	rows, err := file.GetRows(file.GetSheetName(0))
-	if err != nil || len(rows) < 2 {
-		return "Нет данных в файле", nil
-	}
-	header := rows[1]
-	teacherIdx, checkedIdx, totalIdx := -1, -1, -1
-	for i, col := range header {
-		switch strings.ToLower(col) {
-		case "фио преподавателя":
-			teacherIdx = i
-		case "проверено":
-			checkedIdx = i
-		case "получено":
-			totalIdx = i
-		}
-	}
-	if teacherIdx == -1 || checkedIdx == -1 || totalIdx == -1 {
-		return "Не найдены необходимые колонки", nil
-	}
-	var lowPercentTeachers []string
-	for _, row := range rows[1:] {
+func processCheckedHomework(rows *sheetReader, opts processOptions) (*Report, error) {
+	head := rows.head()
+	if len(head) < 2 {
+		return nil, errEmptySheet
+	}
+	// В выгрузке заголовок двухуровневый: «Месяц», «Неделя», «День» над
+	// «Получено» и «Проверено»; берутся первые подходящие колонки, то есть за месяц
+	header, missing := locateHeader(head, []headerColumn{
+		{field: "teacher", required: true},
+		{field: "checked", required: true},
+		{field: "received", required: true},
+	}, opts.columns)
+	if missing != nil {
+		return nil, &missingColumnsError{fields: missing}
+	}
+	teacherIdx, checkedIdx, totalIdx := header.index("teacher"), header.index("checked"), header.index("received")
+	threshold := opts.threshold("checked_homework")
+	lowPercentTeachers := ReportSection{
+		Title:   fmt.Sprintf("Преподаватели с проверкой ниже %s%%:", formatThreshold(threshold)),
+		Columns: []string{"ФИО преподавателя", "Проверено, %"},
+		Style:   listNumbered,
+	}
+	rows.skip(header.dataStart)
+	for rows.next() {
+		row := rows.row()
 		if len(row) <= max(teacherIdx, checkedIdx, totalIdx) {
 			continue
 		}
@@ -557,69 +752,155 @@ func processCheckedHomework(filepath string) (string, error) {
 		total, err2 := strconv.ParseFloat(totalStr, 64)
 		if err1 == nil && err2 == nil && total > 0 {
 			percent := (checked / total) * 100
-			if percent < 70 {
-				lowPercentTeachers = append(lowPercentTeachers, fmt.Sprintf("%s (%.1f%% проверено)", teacher, percent))
+			if percent < threshold {
+				lowPercentTeachers.Rows = append(lowPercentTeachers.Rows, ReportRow{
+					Cells: []string{teacher, fmt.Sprintf("%.1f", percent)},
+					Text:  fmt.Sprintf("%s (%.1f%% проверено)", teacher, percent),
+				})
 			}
 		}
 	}
-	strbuild.WriteString("📝 ОТЧЕТ ПО ПРОВЕРЕННЫМ ДОМАШНИМ ЗАДАНИЯМ\n\n")
-	if len(lowPercentTeachers) > 0 {
-		strbuild.WriteString("Преподаватели с проверкой ниже 70%:\n")
-		for i, t := range lowPercentTeachers {
-			strbuild.WriteString(fmt.Sprintf("%d. %s\n", i+1, t))
-		}
+	report := &Report{Title: "📝 ОТЧЕТ ПО ПРОВЕРЕННЫМ ДОМАШНИМ ЗАДАНИЯМ"}
+	if len(lowPercentTeachers.Rows) > 0 {
+		report.Sections = append(report.Sections, lowPercentTeachers)
 	} else {
-		strbuild.WriteString("✅ Все преподаватели проверяют более 70% заданий")
+		report.Summary = fmt.Sprintf("✅ Все преподаватели проверяют не менее %s%% заданий", formatThreshold(threshold))
 	}
-	return strbuild.String(), nil
+	return report, nil
 }
 
-func processSubmittedHomework(filepath string) (string, error) {
-	file, err := excelize.OpenFile(filepath)
-	if err != nil {
-		return "", err
+func processSubmittedHomework(rows *sheetReader, opts processOptions) (*Report, error) {
+	head := rows.head()
+	if len(head) < 2 {
+		return nil, errEmptySheet
 	}
-	defer file.Close()
 
-	rows, err := file.GetRows(file.GetSheetName(0))
-	if err != nil || len(rows) < 2 {
-		return "Нет данных в файле", nil
+	header, missing := locateHeader(head, []headerColumn{
+		{field: "fio", required: true},
+		{field: "homework_percent", required: true},
+	}, opts.columns)
+	if missing != nil {
+		return nil, &missingColumnsError{fields: missing}
 	}
+	studentIdx, percentIdx := header.index("fio"), header.index("homework_percent")
 
-	header := rows[0]
-	var studentIdx, percentIdx int = -1, -1
-
-	for i, col := range header {
-		colLower := strings.ToLower(col)
-		if colLower == "фио" || colLower == "fio" {
-			studentIdx = i
+	threshold := opts.threshold("submitted_homework")
+	lowStudents := ReportSection{
+		Title:   "ФИО студента - % выполнения:",
+		Columns: []string{"ФИО", "Выполнено, %"},
+		Style:   listNumbered,
+	}
+	rows.skip(header.dataStart)
+	for rows.next() {
+		row := rows.row()
+		if len(row) <= max(studentIdx, percentIdx) {
+			continue
 		}
-		if colLower == "percentage homework" {
-			percentIdx = i
+		fio := strings.TrimSpace(row[studentIdx])
+		// «49%» и «49,5» приводятся к числу, чтобы в выгрузке ячейка была числовой
+		percent := strings.ReplaceAll(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(row[percentIdx]), "%")), ",", ".")
+		percentValue, err := strconv.ParseFloat(percent, 64)
+		if err != nil {
+			continue
+		}
+		if percentValue < threshold {
+			lowStudents.Rows = append(lowStudents.Rows, ReportRow{
+				Cells: []string{fio, percent},
+				Text:  fmt.Sprintf("%s - %s%%", fio, percent),
+			})
 		}
 	}
 
-	if studentIdx == -1 || percentIdx == -1 {
-		return "Не найдены колонки ФИО или процента выполнения", nil
+	report := &Report{Title: "📋 ОТЧЕТ ПО СДАННЫМ ДОМАШНИМ ЗАДАНИЯМ"}
+	if len(lowStudents.Rows) > 0 {
+		report.Sections = append(report.Sections, lowStudents)
+	} else {
+		report.Summary = fmt.Sprintf("✅ Все студенты выполняют %s%% заданий и больше", formatThreshold(threshold))
 	}
+	return report, nil
+}
 
-	strbuild.WriteString("ФИО студента - % выполнения\n\n")
-	for _, row := range rows[1:] {
-		if len(row) <= max(studentIdx, percentIdx) {
+// 7. Студенты с посещаемостью ниже порога, по группам
+func processStudentAttendance(rows *sheetReader, opts processOptions) (*Report, error) {
+	head := rows.head()
+	if len(head) < 2 {
+		return nil, errEmptySheet
+	}
+	header, missing := locateHeader(head, []headerColumn{
+		{field: "fio", required: true},
+		{field: "group"},
+		{field: "attendance", required: true},
+	}, opts.columns)
+	if missing != nil {
+		return nil, &missingColumnsError{fields: missing}
+	}
+	fioIndx, groupIndx, attendanceIndx := header.index("fio"), header.index("group"), header.index("attendance")
+
+	threshold := opts.threshold("student_attendance")
+	type groupStat struct {
+		sum      float64
+		count    int
+		students []ReportRow
+	}
+	groups := make(map[string]*groupStat)
+	rows.skip(header.dataStart)
+	for rows.next() {
+		row := rows.row()
+		if len(row) <= max(fioIndx, attendanceIndx) {
 			continue
 		}
-		fio := strings.TrimSpace(row[studentIdx])
-		percent := strings.TrimSpace(row[percentIdx])
-		percentInt, err := strconv.Atoi(percent)
+		name := strings.TrimSpace(row[fioIndx])
+		attStr := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(row[attendanceIndx]), "%"))
+		if name == "" || attStr == "" {
+			continue
+		}
+		att, err := strconv.ParseFloat(strings.ReplaceAll(attStr, ",", "."), 64)
 		if err != nil {
 			continue
 		}
-		if percentInt < 70 {
-			strbuild.WriteString(fmt.Sprintf("%s - %s%%\n", fio, percent))
+		group := "Без группы"
+		if groupIndx != -1 && len(row) > groupIndx && strings.TrimSpace(row[groupIndx]) != "" {
+			group = strings.TrimSpace(row[groupIndx])
+		}
+		stat, ok := groups[group]
+		if !ok {
+			stat = &groupStat{}
+			groups[group] = stat
+		}
+		stat.sum += att
+		stat.count++
+		if att < threshold {
+			stat.students = append(stat.students, ReportRow{
+				Cells: []string{group, name, fmt.Sprintf("%.1f", att)},
+				Text:  fmt.Sprintf("%s (%.1f%%)", name, att),
+			})
+		}
+	}
+
+	groupNames := make([]string, 0, len(groups))
+	for group, stat := range groups {
+		if len(stat.students) > 0 {
+			groupNames = append(groupNames, group)
 		}
 	}
+	sort.Strings(groupNames)
 
-	return strbuild.String(), nil
+	report := &Report{Title: "🎓 ОТЧЕТ ПО ПОСЕЩАЕМОСТИ СТУДЕНТОВ"}
+	if len(groupNames) == 0 {
+		report.Summary = fmt.Sprintf("✅ У всех студентов посещаемость %s%% и выше", formatThreshold(threshold))
+		return report, nil
+	}
+	report.Intro = fmt.Sprintf("Студенты с посещаемостью ниже %s%%:", formatThreshold(threshold))
+	for _, group := range groupNames {
+		stat := groups[group]
+		report.Sections = append(report.Sections, ReportSection{
+			Title:   fmt.Sprintf("Группа: %s (средняя посещаемость: %.1f%%)", group, stat.sum/float64(stat.count)),
+			Columns: []string{"Группа", "ФИО", "Посещаемость, %"},
+			Rows:    stat.students,
+			Style:   listIndentedNumbered,
+		})
+	}
+	return report, nil
 }
 
 func splitMessage(text string, maxLen int) []string {
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProcessSubmittedHomeworkPercent(t *testing.T) {
+	rows := [][]string{
+		{"FIO", "Группа", "Percentage Homework"},
+		{"Иванов Иван", "ИС-21", "49%"},
+		{"Петров Петр", "ИС-21", "65,5 %"},
+		{"Сидоров Сидор", "ИС-21", "90"},
+		{"Без процента", "ИС-21", ""},
+	}
+	reader := newSheetReader(&sliceRows{rows: rows}, sheetLayout{rows: len(rows), cols: 3})
+	report, err := processSubmittedHomework(reader, processOptions{thresholds: map[string]float64{"submitted_homework": 70}})
+	if err != nil {
+		t.Fatalf("processSubmittedHomework: %v", err)
+	}
+	if len(report.Sections) != 1 {
+		t.Fatalf("разделов: %d, ожидался 1", len(report.Sections))
+	}
+	// Процент без знака и с точкой: в выгрузке ячейка становится числом
+	want := []ReportRow{
+		{Cells: []string{"Иванов Иван", "49"}, Text: "Иванов Иван - 49%"},
+		{Cells: []string{"Петров Петр", "65.5"}, Text: "Петров Петр - 65.5%"},
+	}
+	if got := report.Sections[0].Rows; !reflect.DeepEqual(got, want) {
+		t.Errorf("строки: %+v, ожидалось %+v", got, want)
+	}
+}
+
+func TestProcessStudentsOneRowPerStudent(t *testing.T) {
+	rows := [][]string{
+		{"FIO", "Группа", "Homework", "Classroom"},
+		{"Иванов Иван", "ИС-21", "1", "2"},
+		{"Петров Петр", "ИС-21", "5", "2"},
+		{"Сидоров Сидор", "ИС-21", "1", "9"},
+		{"Кузнецов Кирилл", "ИС-21", "5", "9"},
+	}
+	opts := processOptions{thresholds: map[string]float64{"student_homework": 1, "student_classwork": 3}}
+	process := func(opts processOptions) []ReportRow {
+		reader := newSheetReader(&sliceRows{rows: rows}, sheetLayout{rows: len(rows), cols: 4})
+		report, err := processStudents(reader, opts)
+		if err != nil {
+			t.Fatalf("processStudents: %v", err)
+		}
+		if len(report.Sections) != 1 {
+			t.Fatalf("разделов: %d, ожидался 1", len(report.Sections))
+		}
+		return report.Sections[0].Rows
+	}
+
+	// Иванов отстает по обеим работам, но в отчете указан один раз
+	var names []string
+	for _, row := range process(opts) {
+		names = append(names, row.Cells[0])
+	}
+	if want := []string{"Иванов Иван", "Петров Петр", "Сидоров Сидор"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("студенты в отчете: %q, ожидалось %q", names, want)
+	}
+
+	// Для сводной панели нужны обе оценки
+	opts.allGrades = true
+	var ivanov []string
+	for _, row := range process(opts) {
+		if row.Cells[0] == "Иванов Иван" {
+			ivanov = append(ivanov, row.Cells[1])
+		}
+	}
+	if want := []string{"домашняя", "классная"}; !reflect.DeepEqual(ivanov, want) {
+		t.Errorf("оценки Иванова с allGrades: %q, ожидалось %q", ivanov, want)
+	}
+}
+
+func TestProcessScheduleSample(t *testing.T) {
+	report, err := processorByCallback("mode_schedule").Process("Tz-for-tg-bot/Расписание групп.xls", processOptions{})
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if len(report.Sections) != 1 || report.Sections[0].Title != "Группа: 9/3-РПО-23/2" {
+		t.Fatalf("разделы отчета: %+v", report.Sections)
+	}
+
+	// Считаются дисциплины из колонок дней, а не номера пар
+	counts := make(map[string]string)
+	for _, row := range report.Sections[0].Rows {
+		counts[row.Cells[1]] = row.Cells[2]
+	}
+	want := map[string]string{
+		"Основы алгоритмизации и программирования РПО":                      "4",
+		"Основы разработки приложений с использованием Windows Forms и WPF": "3",
+		"Управление программными проектами РПО":                             "3",
+		"Иностранный язык в профессиональной деятельности РПО":              "2",
+		"Менеджмент в профессиональной деятельности РПО":                    "2",
+		"ОГСЭ.05 Физическая культура":                                       "2",
+	}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("пары по дисциплинам: %v, ожидалось %v", counts, want)
+	}
+}
+
+func TestProcessSchedulePairColumn(t *testing.T) {
+	// Без колонок дней дисциплина берется из колонки «Пара», номера пропускаются
+	rows := [][]string{
+		{"Группа", "Пара", "Время"},
+		{"ИС-21", "Математика", "09:00"},
+		{"ИС-21", "Математика", "10:40"},
+		{"ИС-21", "3", "12:20"},
+	}
+	reader := newSheetReader(&sliceRows{rows: rows}, sheetLayout{rows: len(rows), cols: 3})
+	report, err := processSchedule(reader, processOptions{})
+	if err != nil {
+		t.Fatalf("processSchedule: %v", err)
+	}
+	want := []ReportRow{{Cells: []string{"ИС-21", "Математика", "2"}, Text: "Математика: 2 пар"}}
+	if len(report.Sections) != 1 || !reflect.DeepEqual(report.Sections[0].Rows, want) {
+		t.Errorf("отчет: %+v", report.Sections)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Метрики в текстовом формате Prometheus. Сервер метрик включается
+// переменной metrics_listen (например, 127.0.0.1:9090) и отдает /metrics
+const (
+	metricsPath = "/metrics"
+	// Чат считается активным, если от него было обновление за это время
+	activeChatWindow = 24 * time.Hour
+	// Как часто забывать давние чаты, если метрики никто не читает
+	activeChatPruneInterval = time.Hour
+)
+
+// Границы корзин гистограмм: время обработки в секундах и размер файла в байтах
+var (
+	latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	sizeBuckets    = []float64{10 << 10, 50 << 10, 100 << 10, 500 << 10, 1 << 20, 5 << 20, 10 << 20, 20 << 20}
+)
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, le := range h.buckets {
+		if v <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// Все метрики бота под одной блокировкой: обновляются они редко,
+// по одному разу на файл или обновление
+type botMetrics struct {
+	mu          sync.Mutex
+	updates     map[string]uint64
+	files       map[string]uint64
+	errors      map[string]uint64
+	processing  map[string]*histogram
+	downloads   *histogram
+	chatsSeenAt map[int64]time.Time
+	prunedAt    time.Time
+}
+
+var metrics = &botMetrics{
+	updates:     make(map[string]uint64),
+	files:       make(map[string]uint64),
+	errors:      make(map[string]uint64),
+	processing:  make(map[string]*histogram),
+	downloads:   newHistogram(sizeBuckets),
+	chatsSeenAt: make(map[int64]time.Time),
+}
+
+func (m *botMetrics) countUpdate(update tgbotapi.Update) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.updates[updateKind(update)]++
+	if chat := update.FromChat(); chat != nil {
+		m.seeChat(chat.ID, time.Now())
+	}
+}
+
+// Отметка обновления от чата. Давние чаты забываются и здесь, а не только
+// при чтении метрик: без сервера метрик карта иначе росла бы без конца
+func (m *botMetrics) seeChat(chatID int64, now time.Time) {
+	m.chatsSeenAt[chatID] = now
+	if now.Sub(m.prunedAt) >= activeChatPruneInterval {
+		m.pruneChats(now)
+	}
+}
+
+// Итог обработки файла: тип, определенный по заголовкам (unknown — тип не
+// определен), и вид ошибки, если файл не обработан
+func (m *botMetrics) countFileOutcome(entry auditEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case entry.Category != "":
+		m.files[strings.TrimPrefix(entry.Category, "mode_")]++
+	case entry.Outcome == outcomeUnknown:
+		m.files["unknown"]++
+	}
+	switch entry.Outcome {
+	case outcomeDownload:
+		m.errors["download"]++
+	case outcomeUnknown:
+		m.errors["unknown_type"]++
+	case outcomeTooLarge:
+		m.errors["too_large"]++
+	case outcomeError:
+		if entry.err != nil {
+			m.errors[errorType(entry.err)]++
+		} else {
+			m.errors["processing"]++
+		}
+	}
+}
+
+func (m *botMetrics) countError(kind string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors[kind]++
+}
+
+// Время обработки файла обработчиком с момента started
+func (m *botMetrics) observeProcessing(processor string, started time.Time) {
+	elapsed := time.Since(started)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.processing[processor]
+	if !ok {
+		h = newHistogram(latencyBuckets)
+		m.processing[processor] = h
+	}
+	h.observe(elapsed.Seconds())
+}
+
+func (m *botMetrics) observeDownload(size int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.downloads.observe(float64(size))
+}
+
+// Чаты, от которых были обновления за activeChatWindow
+func (m *botMetrics) activeChats(now time.Time) int {
+	m.pruneChats(now)
+	return len(m.chatsSeenAt)
+}
+
+func (m *botMetrics) pruneChats(now time.Time) {
+	for chatID, seen := range m.chatsSeenAt {
+		if now.Sub(seen) > activeChatWindow {
+			delete(m.chatsSeenAt, chatID)
+		}
+	}
+	m.prunedAt = now
+}
+
+func (m *botMetrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	writeCounter(w, "report_bot_updates_total", "Обновления Telegram по видам", "kind", m.updates)
+	writeCounter(w, "report_bot_files_total", "Файлы по типам, определенным по заголовкам", "category", m.files)
+	writeCounter(w, "report_bot_errors_total", "Ошибки по видам", "type", m.errors)
+
+	fmt.Fprintf(w, "# HELP report_bot_processing_seconds Время обработки файла по обработчикам\n")
+	fmt.Fprintf(w, "# TYPE report_bot_processing_seconds histogram\n")
+	for _, processor := range sortedKeys(m.processing) {
+		writeHistogram(w, "report_bot_processing_seconds", "processor="+labelValue(processor)+",", m.processing[processor])
+	}
+	fmt.Fprintf(w, "# HELP report_bot_download_bytes Размер скачанных файлов\n")
+	fmt.Fprintf(w, "# TYPE report_bot_download_bytes histogram\n")
+	writeHistogram(w, "report_bot_download_bytes", "", m.downloads)
+
+	fmt.Fprintf(w, "# HELP report_bot_active_chats Чаты с обновлениями за последние сутки\n")
+	fmt.Fprintf(w, "# TYPE report_bot_active_chats gauge\n")
+	fmt.Fprintf(w, "report_bot_active_chats %d\n", m.activeChats(time.Now()))
+}
+
+func writeCounter(w io.Writer, name, help, label string, values map[string]uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	for _, key := range sortedKeys(values) {
+		fmt.Fprintf(w, "%s{%s=%s} %d\n", name, label, labelValue(key), values[key])
+	}
+}
+
+// labels — уже записанные метки с запятой в конце или пустая строка
+func writeHistogram(w io.Writer, name, labels string, h *histogram) {
+	for i, le := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{%sle=\"%s\"} %d\n", name, labels, strconv.FormatFloat(le, 'g', -1, 64), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{%sle=\"+Inf\"} %d\n", name, labels, h.count)
+	labels = strings.TrimSuffix(labels, ",")
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(w, "%s_sum%s %s\n", name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count%s %d\n", name, labels, h.count)
+}
+
+// Значение метки: в формате Prometheus экранируются \, " и перевод строки
+func labelValue(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Сервер метрик; возвращаемая функция останавливает его
+func serveMetrics(addr string) (func(), error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(metricsPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		metrics.write(w)
+	})
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			slog.Error("Ошибка сервера метрик", "err", err)
+		}
+	}()
+	slog.Info("Метрики доступны", "url", "http://"+listener.Addr().String()+metricsPath)
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+		defer cancel()
+		server.Shutdown(ctx)
+	}, nil
+}
+
+func metricsListen() string {
+	return os.Getenv("metrics_listen")
+}
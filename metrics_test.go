@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSeeChatPrunesIdleChats(t *testing.T) {
+	m := &botMetrics{chatsSeenAt: make(map[int64]time.Time)}
+	start := time.Date(2025, time.December, 15, 9, 0, 0, 0, time.UTC)
+
+	// Без чтения метрик давние чаты все равно забываются
+	for i := range 1000 {
+		m.seeChat(int64(i), start)
+	}
+	m.seeChat(-1, start.Add(activeChatWindow+activeChatPruneInterval))
+	if len(m.chatsSeenAt) != 1 {
+		t.Errorf("чатов в памяти: %d, ожидался 1", len(m.chatsSeenAt))
+	}
+
+	// Чаты внутри окна остаются
+	m.seeChat(-2, start.Add(activeChatWindow+2*activeChatPruneInterval))
+	if got := m.activeChats(start.Add(activeChatWindow + 2*activeChatPruneInterval)); got != 2 {
+		t.Errorf("активных чатов: %d, ожидалось 2", got)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Формат, в котором бот возвращает отчет. Для текста render не задан:
+// отчет отправляется сообщениями, остальные форматы — документом
+type outputFormat struct {
+	key    string
+	label  string
+	ext    string
+	render func(r *Report) ([]byte, error)
+}
+
+// Доступные форматы; первый используется по умолчанию
+var outputFormats = []outputFormat{
+	{key: "text", label: "Текст"},
+	{key: "xlsx", label: "Excel", ext: ".xlsx", render: renderXLSX},
+	{key: "pdf", label: "PDF", ext: ".pdf", render: renderPDF},
+	{key: "csv", label: "CSV", ext: ".csv", render: renderCSV},
+	{key: "json", label: "JSON", ext: ".json", render: renderJSON},
+}
+
+func outputFormatByKey(key string) (outputFormat, bool) {
+	for _, f := range outputFormats {
+		if f.key == key {
+			return f, true
+		}
+	}
+	return outputFormat{}, false
+}
+
+// Формат, выбранный в чате командой /format
+func chatOutputFormat(chatID int64) outputFormat {
+	if f, ok := outputFormatByKey(getChatSettings(chatID).Preferences["format"]); ok {
+		return f
+	}
+	return outputFormats[0]
+}
+
+func setChatOutputFormat(chatID int64, key string) error {
+	return updateChatSettings(chatID, func(s *ChatSettings) {
+		if s.Preferences == nil {
+			s.Preferences = make(map[string]string)
+		}
+		s.Preferences["format"] = key
+	})
+}
+
+// Отправка отчета в формате чата. Отчет без разделов (например, «Нет данных
+// в файле») всегда отправляется текстом
+func sendReport(bot *tgbotapi.BotAPI, chatID int64, report *Report) {
+	format := chatOutputFormat(chatID)
+	if format.render == nil || len(report.Sections) == 0 {
+		sendText(bot, chatID, renderText(report))
+		return
+	}
+
+	data, err := format.render(report)
+	if err != nil {
+		slog.Error("Не удалось сформировать отчет", "chat_id", chatID, "format", format.key, "err", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "Не удалось сформировать файл отчета, отправляю текстом"))
+		sendText(bot, chatID, renderText(report))
+		return
+	}
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: reportFileName(report.Name, format.ext), Bytes: data})
+	doc.Caption = report.Title
+	bot.Send(doc)
+}
+
+// Текст длиннее лимита Telegram отправляется несколькими сообщениями
+func sendText(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	for _, part := range splitMessage(text, 4000) {
+		bot.Send(tgbotapi.NewMessage(chatID, part))
+	}
+}
+
+// Имя файла отчета: тип отчета и дата формирования
+func reportFileName(name, ext string) string {
+	return fmt.Sprintf("%s %s%s", name, time.Now().Format("2006-01-02"), ext)
+}
+
+// Команды /format и /export: с аргументом сразу меняет формат, без него — показывает кнопки
+func handleFormatCommand(bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	if arg := strings.ToLower(strings.TrimSpace(msg.CommandArguments())); arg != "" {
+		format, ok := outputFormatByKey(arg)
+		if !ok {
+			keys := make([]string, len(outputFormats))
+			for i, f := range outputFormats {
+				keys[i] = f.key
+			}
+			bot.Send(tgbotapi.NewMessage(chatID, "Неизвестный формат. Доступны: "+strings.Join(keys, ", ")))
+			return
+		}
+		if err := setChatOutputFormat(chatID, format.key); err != nil {
+			slog.Error("Не удалось сохранить настройки чата", "chat_id", chatID, "err", err)
+		}
+		bot.Send(tgbotapi.NewMessage(chatID, "Формат отчетов: "+format.label))
+		return
+	}
+
+	var buttons []tgbotapi.InlineKeyboardButton
+	for _, f := range outputFormats {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(f.label, "format_"+f.key))
+	}
+	reply := tgbotapi.NewMessage(chatID, fmt.Sprintf("Сейчас отчеты приходят в формате: %s\nВыберите формат:", chatOutputFormat(chatID).label))
+	reply.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons)
+	bot.Send(reply)
+}
+
+func handleFormatCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	chatID := callback.Message.Chat.ID
+	format, ok := outputFormatByKey(strings.TrimPrefix(callback.Data, "format_"))
+	if !ok {
+		bot.Request(tgbotapi.NewCallback(callback.ID, "Неизвестный формат"))
+		return
+	}
+	if err := setChatOutputFormat(chatID, format.key); err != nil {
+		slog.Error("Не удалось сохранить настройки чата", "chat_id", chatID, "err", err)
+	}
+	bot.Request(tgbotapi.NewCallback(callback.ID, "Формат: "+format.label))
+	bot.Send(tgbotapi.NewMessage(chatID, "Формат отчетов установлен: "+format.label))
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Как часто обновлять сообщение о ходе обработки. Telegram ограничивает
+// частоту правок, а небольшой файл успевает обработаться до первой правки
+const progressInterval = 3 * time.Second
+
+// Ход обработки большого файла в сообщении «⏳ Обрабатываю файл...».
+// Обработчик только запоминает, сколько строк прочитано, а сообщение
+// правится отдельно, чтобы запросы к Telegram не замедляли обработку
+type processingProgress struct {
+	mu    sync.Mutex
+	sheet string
+	read  int
+	total int
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func startProgress(bot *tgbotapi.BotAPI, chatID int64, messageID int) *processingProgress {
+	p := &processingProgress{stop: make(chan struct{}), done: make(chan struct{})}
+	go p.run(bot, chatID, messageID)
+	return p
+}
+
+// Прочитано read строк листа sheet из total (0 — размер неизвестен)
+func (p *processingProgress) update(sheet string, read, total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sheet, p.read, p.total = sheet, read, total
+}
+
+// Остановка правок; после нее сообщение можно удалить
+func (p *processingProgress) finish() {
+	close(p.stop)
+	<-p.done
+}
+
+func (p *processingProgress) run(bot *tgbotapi.BotAPI, chatID int64, messageID int) {
+	defer close(p.done)
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	shown := ""
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+		if text := p.text(); text != "" && text != shown {
+			bot.Send(tgbotapi.NewEditMessageText(chatID, messageID, text))
+			shown = text
+		}
+	}
+}
+
+func (p *processingProgress) text() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.read == 0 {
+		return ""
+	}
+	// Размер в файле бывает записан неверно, тогда показывается только число строк
+	if p.total >= p.read {
+		return fmt.Sprintf("⏳ Обрабатываю файл... Лист «%s»: %d%% (%d из %d строк)", p.sheet, p.read*100/p.total, p.read, p.total)
+	}
+	return fmt.Sprintf("⏳ Обрабатываю файл... Лист «%s»: прочитано строк: %d", p.sheet, p.read)
+}
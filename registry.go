@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// Обработчик отчета одного типа. Чтобы добавить новый тип отчета, достаточно
+// реализовать этот интерфейс и добавить обработчик в список processors
+type Processor interface {
+	// Название типа отчета, которое показывается пользователю
+	Name() string
+	// Короткая подпись кнопки выбора режима
+	Label() string
+	// Данные кнопки выбора режима, они же сохраняются как режим чата
+	CallbackID() string
+	// Уверенность от 0 до 1, что строка заголовка (ячейки в нижнем регистре)
+	// принадлежит отчету этого типа
+	Detect(header []string) float64
+	// Обработка выбранных листов файла и подготовка отчета с порогами чата
+	Process(filepath string, opts processOptions) (*Report, error)
+}
+
+// Обработчик, собранный из функций
+type reportProcessor struct {
+	name       string
+	label      string
+	callbackID string
+	signals    []headerSignal
+	// Обработка строк одного листа
+	process func(rows *sheetReader, opts processOptions) (*Report, error)
+}
+
+func (p reportProcessor) Name() string       { return p.name }
+func (p reportProcessor) Label() string      { return p.label }
+func (p reportProcessor) CallbackID() string { return p.callbackID }
+func (p reportProcessor) Detect(header []string) float64 {
+	return scoreHeader(p.signals, header)
+}
+func (p reportProcessor) Process(filepath string, opts processOptions) (*Report, error) {
+	defer metrics.observeProcessing(processorKey(p), time.Now())
+	wb, err := openWorkbook(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer wb.Close()
+	report, err := processSheets(wb, opts, p.process)
+	// Режим не подходит к файлу: подсказка, какие типы отчетов подходят
+	var missing *missingColumnsError
+	if errors.As(err, &missing) {
+		for _, c := range determineFileType(filepath) {
+			if c.processor.CallbackID() != p.callbackID && c.confidence >= detectMinConfidence && len(missing.suggestions) < detectMaxChoices {
+				missing.suggestions = append(missing.suggestions, "«"+c.processor.Name()+"»")
+			}
+		}
+	}
+	return report, err
+}
+
+// Все типы отчетов. Порядок задает расположение кнопок и очередность автоопределения
+var processors = []Processor{
+	reportProcessor{
+		name:       "Расписание групп",
+		label:      "Расписание групп",
+		callbackID: "mode_schedule",
+		signals: []headerSignal{
+			headerWords(1, "группа"),
+			headerWords(1, "пара"),
+			headerWords(1, "время"),
+			headerWords(0.5, "понедельник", "вторник", "среда", "четверг", "пятница", "суббота"),
+		},
+		process: processSchedule,
+	},
+	reportProcessor{
+		name:       "Темы уроков",
+		label:      "Темы уроков",
+		callbackID: "mode_lessons",
+		signals: []headerSignal{
+			headerWords(2, "тема урока", "тема занятия"),
+			headerWords(1, "урок", "занятие"),
+			headerWords(0.5, "предмет", "дисциплина"),
+			headerWords(0.5, "date", "дата"),
+		},
+		process: processLessonTopics,
+	},
+	reportProcessor{
+		name:       "Отчет по студентам",
+		label:      "Студенты",
+		callbackID: "mode_students",
+		signals: []headerSignal{
+			headerWords(1, "fio", "фио"),
+			headerWords(1, "homework", "домашн"),
+			headerWords(1, "classroom", "classwork", "классн"),
+			// Выгрузка посещаемости студентов тоже содержит FIO, ее забирает отдельный обработчик
+			headerWords(-1, "посещаем", "attendance"),
+			headerWords(-1, "преподавател"),
+		},
+		process: processStudents,
+	},
+	reportProcessor{
+		name:       "Посещаемость по преподавателям",
+		label:      "Посещаемость",
+		callbackID: "mode_attendance",
+		signals: []headerSignal{
+			headerWords(2, "фио преподавателя", "преподаватель"),
+			headerWords(2, "средняя посещаемость"),
+			headerWords(0.5, "всего пар"),
+			headerWords(0.5, "всего групп"),
+		},
+		process: processAttendance,
+	},
+	reportProcessor{
+		name:       "Отчет по посещаемости студентов",
+		label:      "Посещаемость студентов",
+		callbackID: "mode_student_attendance",
+		signals: []headerSignal{
+			headerWords(1, "fio", "фио"),
+			headerWords(2, "посещаем", "attendance"),
+			headerWords(0.5, "группа", "group"),
+			headerWords(-2, "преподавател"),
+		},
+		process: processStudentAttendance,
+	},
+	reportProcessor{
+		name:       "Отчет по проверенным ДЗ",
+		label:      "Проверенные ДЗ",
+		callbackID: "mode_checked_homework",
+		signals: []headerSignal{
+			headerWords(1, "фио преподавателя", "преподаватель"),
+			headerWords(2, "проверено"),
+			headerWords(1, "получено"),
+			headerWords(0.5, "выдано"),
+			headerWords(0.5, "форма обучения"),
+			headerWords(0.5, "месяц", "неделя", "день"),
+		},
+		process: processCheckedHomework,
+	},
+	reportProcessor{
+		name:       "Отчет по сданным ДЗ",
+		label:      "Сданные ДЗ",
+		callbackID: "mode_submitted_homework",
+		signals: []headerSignal{
+			headerWords(1, "fio", "фио"),
+			headerWords(2, "percentage homework", "процент выполнения", "% выполнения"),
+			headerWords(-1, "преподавател"),
+			// Процент ДЗ есть и в полной выгрузке по студентам, но оценки за
+			// классную работу бывают только в ней, ее забирает отчет по студентам
+			headerWords(-1, "classroom", "classwork", "классн"),
+		},
+		process: processSubmittedHomework,
+	},
+}
+
+// Поиск обработчика по данным кнопки (и сохраненному режиму чата)
+func processorByCallback(callbackID string) Processor {
+	for _, p := range processors {
+		if p.CallbackID() == callbackID {
+			return p
+		}
+	}
+	return nil
+}
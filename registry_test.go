@@ -0,0 +1,25 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestStudentAttendanceSuggestsMatchingMode(t *testing.T) {
+	// Выгрузка посещаемости в примерах совпадает с отчетом по студентам:
+	// колонки посещаемости в ней нет, и бот должен подсказать другой режим
+	p := processorByCallback("mode_student_attendance")
+	_, err := p.Process("Tz-for-tg-bot/Отчет по посещаемости студентов.xlsx", processOptions{})
+	var missing *missingColumnsError
+	if !errors.As(err, &missing) {
+		t.Fatalf("ошибка: %v, ожидалась missingColumnsError", err)
+	}
+	if !slices.Equal(missing.fields, []string{"attendance"}) {
+		t.Errorf("не найдены колонки: %q, ожидалась только attendance", missing.fields)
+	}
+	students := "«" + processorByCallback("mode_students").Name() + "»"
+	if !slices.Contains(missing.suggestions, students) {
+		t.Errorf("подсказки: %q, ожидался режим %s", missing.suggestions, students)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Результат обработки файла. Каждый обработчик собирает свой отчет, а
+// представление (текст сообщения, файл) строит отдельный рендерер
+type Report struct {
+	// Тип отчета (название обработчика), заполняется при обработке файла
+	Name     string          `json:"name"`
+	Title    string          `json:"title"`
+	Intro    string          `json:"intro,omitempty"`
+	Sections []ReportSection `json:"sections,omitempty"`
+	// Итоговая строка, например «✅ Все студенты успешно справляются»
+	Summary string `json:"summary,omitempty"`
+}
+
+// Способ вывода строк раздела в текстовом отчете
+type listStyle int
+
+const (
+	listNumbered listStyle = iota
+	listBulleted
+	listIndented
+	listIndentedNumbered
+)
+
+// Раздел отчета: заголовок и строки таблицы
+type ReportSection struct {
+	Title   string      `json:"title"`
+	Columns []string    `json:"columns,omitempty"`
+	Rows    []ReportRow `json:"rows,omitempty"`
+	Style   listStyle   `json:"style"`
+}
+
+// Строка раздела: значения по колонкам для табличных форматов и готовый
+// текст для сообщения в чат
+type ReportRow struct {
+	Cells []string `json:"cells"`
+	Text  string   `json:"text"`
+}
+
+// Отчет, состоящий из одного сообщения (например, «Нет загруженных отчетов»)
+func messageReport(text string) *Report {
+	return &Report{Summary: text}
+}
+
+// Объединение нескольких отчетов (листов книги, файлов архива) в один:
+// разделы получают название источника, итоговые строки собираются в общий итог
+func mergeReports(sources []string, reports []*Report) *Report {
+	merged := &Report{}
+	var summaries []string
+	for i, r := range reports {
+		if merged.Title == "" {
+			merged.Title = r.Title
+		}
+		if merged.Intro == "" {
+			merged.Intro = r.Intro
+		}
+		for _, s := range r.Sections {
+			if s.Title == "" {
+				s.Title = sources[i]
+			} else {
+				s.Title = sources[i] + " — " + s.Title
+			}
+			merged.Sections = append(merged.Sections, s)
+		}
+		if r.Summary != "" {
+			summaries = append(summaries, sources[i]+": "+r.Summary)
+		}
+	}
+	merged.Summary = strings.Join(summaries, "\n")
+	return merged
+}
+
+// Текстовое представление отчета для отправки сообщением
+func renderText(r *Report) string {
+	var b strings.Builder
+	if r.Title != "" {
+		b.WriteString(r.Title + "\n\n")
+	}
+	if r.Intro != "" {
+		b.WriteString(r.Intro + "\n\n")
+	}
+	for _, s := range r.Sections {
+		if s.Title != "" {
+			b.WriteString(s.Title + "\n")
+		}
+		for i, row := range s.Rows {
+			switch s.Style {
+			case listNumbered:
+				b.WriteString(fmt.Sprintf("%d. %s\n", i+1, row.Text))
+			case listBulleted:
+				b.WriteString(fmt.Sprintf("• %s\n", row.Text))
+			case listIndented:
+				b.WriteString(fmt.Sprintf("  %s\n", row.Text))
+			case listIndentedNumbered:
+				b.WriteString(fmt.Sprintf("  %d. %s\n", i+1, row.Text))
+			}
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(r.Summary)
+	return strings.TrimSpace(b.String())
+}
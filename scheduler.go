@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Что отправлять по расписанию
+const (
+	// Последние загруженные отчеты каждого типа как есть
+	scheduleKindReport = "report"
+	// Одна сводка по всем отмеченным преподавателям и студентам
+	scheduleKindDigest = "digest"
+)
+
+// Сокращения расписаний, как в cron
+var cronMacros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// Расписание в формате cron: минута, час, день месяца, месяц, день недели.
+// Поддерживаются *, числа, списки через запятую, диапазоны и шаг (*/15, 1-5/2)
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// Если ограничены и день месяца, и день недели, достаточно совпадения одного из них
+	domAny, dowAny bool
+}
+
+func parseCron(spec string) (cronSchedule, error) {
+	if macro, ok := cronMacros[spec]; ok {
+		spec = macro
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return cronSchedule{}, fmt.Errorf("нужно 5 полей: минута, час, день месяца, месяц, день недели")
+	}
+	var c cronSchedule
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return c, fmt.Errorf("минута: %w", err)
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return c, fmt.Errorf("час: %w", err)
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return c, fmt.Errorf("день месяца: %w", err)
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return c, fmt.Errorf("месяц: %w", err)
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return c, fmt.Errorf("день недели: %w", err)
+	}
+	// Воскресенье можно записать и как 0, и как 7
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	// Как в cron: поле, начинающееся со «*» (в том числе «*/2»), не ограничивает день
+	c.domAny = strings.HasPrefix(fields[2], "*")
+	c.dowAny = strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+// Поле расписания как набор битов разрешенных значений
+func parseCronField(field string, lo, hi int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("некорректный шаг %q", stepPart)
+			}
+			step = n
+		}
+		from, to := lo, hi
+		if rangePart != "*" {
+			first, last, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if from, err = strconv.Atoi(first); err != nil {
+				return 0, fmt.Errorf("некорректное значение %q", part)
+			}
+			to = from
+			if isRange {
+				if to, err = strconv.Atoi(last); err != nil {
+					return 0, fmt.Errorf("некорректное значение %q", part)
+				}
+			} else if hasStep {
+				to = hi
+			}
+		}
+		if from < lo || to > hi || from > to {
+			return 0, fmt.Errorf("значение %q вне диапазона %d-%d", part, lo, hi)
+		}
+		for v := from; v <= to; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func (c cronSchedule) matches(t time.Time) bool {
+	if c.minute&(1<<t.Minute()) == 0 || c.hour&(1<<t.Hour()) == 0 || c.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	domOK := c.dom&(1<<t.Day()) != 0
+	dowOK := c.dow&(1<<int(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
+
+// Ближайшее время отправки после after; нулевое время, если его нет в течение года
+func (c cronSchedule) next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	for end := t.AddDate(1, 0, 0); t.Before(end); t = t.Add(time.Minute) {
+		if c.matches(t) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Планировщик: раз в минуту проверяет расписания чатов. Время — локальное
+// время сервера. Пропущенные, пока бот был остановлен, отправки не догоняются
+func runScheduler(ctx context.Context, bot *tgbotapi.BotAPI) {
+	for {
+		next := time.Now().Truncate(time.Minute).Add(time.Minute)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+		for chatID, schedule := range scheduledChats() {
+			c, err := parseCron(schedule.Spec)
+			if err != nil {
+				slog.Warn("Некорректное расписание чата", "chat_id", chatID, "spec", schedule.Spec, "err", err)
+				continue
+			}
+			if access.role(schedule.By, chatID) < commandRole("schedule") {
+				continue
+			}
+			if c.matches(next) {
+				sendScheduledReports(bot, chatID, schedule.Kind)
+			}
+		}
+	}
+}
+
+// Плановая отправка: сводка или последние отчеты из истории чата
+func sendScheduledReports(bot *tgbotapi.BotAPI, chatID int64, kind string) {
+	var kinds []Processor
+	if history != nil {
+		kinds = history.kinds(chatID, 1)
+	}
+	if len(kinds) == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, "🗓 Плановая рассылка: пока нет загруженных отчетов"))
+		return
+	}
+	if kind == scheduleKindDigest {
+		sendReport(bot, chatID, buildDigest(chatID, kinds))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, "🗓 Плановая рассылка: последние загруженные отчеты"))
+	for _, p := range kinds {
+		if entries := history.last(chatID, p.CallbackID(), 1); len(entries) > 0 {
+			sendReport(bot, chatID, entries[0].Report)
+		}
+	}
+}
+
+// Сводка по последним загрузкам всех типов отчетов: отмеченные строки
+// каждого отчета одним документом
+func buildDigest(chatID int64, kinds []Processor) *Report {
+	digest := &Report{
+		Name:  "Сводка",
+		Title: "🗓 СВОДКА ПО ПОСЛЕДНИМ ОТЧЕТАМ",
+	}
+	var clean []string
+	for _, p := range kinds {
+		entries := history.last(chatID, p.CallbackID(), 1)
+		if len(entries) == 0 {
+			continue
+		}
+		entry := entries[0]
+		source := fmt.Sprintf("%s (%s)", p.Name(), entry.Time.Format("02.01.2006"))
+		if len(entry.Report.Sections) == 0 {
+			clean = append(clean, source)
+			continue
+		}
+		for _, s := range entry.Report.Sections {
+			s.Title = source + " — " + s.Title
+			digest.Sections = append(digest.Sections, s)
+		}
+	}
+	if len(clean) > 0 {
+		digest.Summary = "✅ Без замечаний: " + strings.Join(clean, ", ")
+	}
+	return digest
+}
+
+func scheduleKindLabel(kind string) string {
+	if kind == scheduleKindReport {
+		return "последние отчеты"
+	}
+	return "сводка"
+}
+
+func setChatSchedule(chatID int64, schedule *ReportSchedule) error {
+	return updateChatSettings(chatID, func(s *ChatSettings) {
+		s.Schedule = schedule
+	})
+}
+
+// Команда /schedule: без аргументов — текущее расписание и подсказка,
+// «/schedule <cron> [digest|report]» — подписка, «/schedule off» — отписка
+func handleScheduleCommand(bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	args := strings.Fields(strings.ToLower(msg.CommandArguments()))
+
+	if len(args) == 0 {
+		text := "Плановая рассылка не настроена"
+		if schedule := getChatSettings(chatID).Schedule; schedule != nil {
+			text = fmt.Sprintf("Плановая рассылка: %s по расписанию «%s»", scheduleKindLabel(schedule.Kind), schedule.Spec)
+			if c, err := parseCron(schedule.Spec); err == nil {
+				if next := c.next(time.Now()); !next.IsZero() {
+					text += "\nСледующая отправка: " + next.Format("02.01.2006 15:04")
+				}
+			}
+		}
+		text += "\n\nНастроить: /schedule <минута> <час> <день> <месяц> <день недели> [digest|report]\n" +
+			"Например, сводка по понедельникам в 9:00: /schedule 0 9 * * 1 digest\n" +
+			"digest — сводка по отмеченным преподавателям и студентам, report — последние отчеты целиком\n" +
+			"Отключить: /schedule off"
+		bot.Send(tgbotapi.NewMessage(chatID, text))
+		return
+	}
+
+	if len(args) == 1 && args[0] == "off" {
+		if err := setChatSchedule(chatID, nil); err != nil {
+			slog.Error("Не удалось сохранить настройки чата", "chat_id", chatID, "err", err)
+		}
+		bot.Send(tgbotapi.NewMessage(chatID, "Плановая рассылка отключена"))
+		return
+	}
+
+	kind := scheduleKindDigest
+	if last := args[len(args)-1]; last == scheduleKindDigest || last == scheduleKindReport {
+		kind = last
+		args = args[:len(args)-1]
+	}
+	spec := strings.Join(args, " ")
+	c, err := parseCron(spec)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Некорректное расписание: %v\nПример: /schedule 0 9 * * 1 digest", err)))
+		return
+	}
+	schedule := &ReportSchedule{Spec: spec, Kind: kind}
+	if msg.From != nil {
+		schedule.By = msg.From.ID
+	}
+	if err := setChatSchedule(chatID, schedule); err != nil {
+		slog.Error("Не удалось сохранить настройки чата", "chat_id", chatID, "err", err)
+	}
+	text := fmt.Sprintf("Плановая рассылка настроена: %s по расписанию «%s»", scheduleKindLabel(kind), spec)
+	if next := c.next(time.Now()); !next.IsZero() {
+		text += "\nСледующая отправка: " + next.Format("02.01.2006 15:04")
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, text))
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Набор битов из перечисленных значений
+func cronBits(values ...int) uint64 {
+	var bits uint64
+	for _, v := range values {
+		bits |= 1 << v
+	}
+	return bits
+}
+
+func allDays() []int {
+	days := make([]int, 0, 31)
+	for d := 1; d <= 31; d++ {
+		days = append(days, d)
+	}
+	return days
+}
+
+func allMonths() []int {
+	return []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+}
+
+func TestParseCronField(t *testing.T) {
+	tests := []struct {
+		field  string
+		lo, hi int
+		want   uint64
+		fail   bool
+	}{
+		{field: "*", lo: 0, hi: 6, want: cronBits(0, 1, 2, 3, 4, 5, 6)},
+		{field: "*/15", lo: 0, hi: 59, want: cronBits(0, 15, 30, 45)},
+		{field: "1-5/2", lo: 0, hi: 7, want: cronBits(1, 3, 5)},
+		{field: "10/20", lo: 0, hi: 59, want: cronBits(10, 30, 50)},
+		{field: "1,15,31", lo: 1, hi: 31, want: cronBits(1, 15, 31)},
+		{field: "1-3,6", lo: 1, hi: 12, want: cronBits(1, 2, 3, 6)},
+		{field: "7", lo: 0, hi: 7, want: cronBits(7)},
+		{field: "60", lo: 0, hi: 59, fail: true},
+		{field: "0", lo: 1, hi: 31, fail: true},
+		{field: "5-1", lo: 0, hi: 23, fail: true},
+		{field: "1-32", lo: 1, hi: 31, fail: true},
+		{field: "*/0", lo: 0, hi: 59, fail: true},
+		{field: "*/x", lo: 0, hi: 59, fail: true},
+		{field: "a", lo: 0, hi: 59, fail: true},
+		{field: "1-", lo: 0, hi: 59, fail: true},
+		{field: "", lo: 0, hi: 59, fail: true},
+	}
+	for _, tt := range tests {
+		got, err := parseCronField(tt.field, tt.lo, tt.hi)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("parseCronField(%q, %d, %d) = %b, ожидалась ошибка", tt.field, tt.lo, tt.hi, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseCronField(%q, %d, %d) = %b, %v, ожидалось %b", tt.field, tt.lo, tt.hi, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		spec string
+		want cronSchedule
+		fail bool
+	}{
+		{spec: "30 9 * * 1-5", want: cronSchedule{
+			minute: cronBits(30), hour: cronBits(9), dom: cronBits(allDays()...), month: cronBits(allMonths()...),
+			dow: cronBits(1, 2, 3, 4, 5), domAny: true,
+		}},
+		// 7 — тоже воскресенье
+		{spec: "0 18 * * 7", want: cronSchedule{
+			minute: cronBits(0), hour: cronBits(18), dom: cronBits(allDays()...), month: cronBits(allMonths()...),
+			dow: cronBits(0, 7), domAny: true,
+		}},
+		{spec: "@weekly", want: cronSchedule{
+			minute: cronBits(0), hour: cronBits(0), dom: cronBits(allDays()...), month: cronBits(allMonths()...),
+			dow: cronBits(0), domAny: true,
+		}},
+		{spec: "@monthly", want: cronSchedule{
+			minute: cronBits(0), hour: cronBits(0), dom: cronBits(1), month: cronBits(allMonths()...),
+			dow: cronBits(0, 1, 2, 3, 4, 5, 6, 7), dowAny: true,
+		}},
+		// Шаг от «*» тоже не ограничивает день
+		{spec: "0 9 */10 * 1", want: cronSchedule{
+			minute: cronBits(0), hour: cronBits(9), dom: cronBits(1, 11, 21, 31), month: cronBits(allMonths()...),
+			dow: cronBits(1), domAny: true,
+		}},
+		{spec: "0 9 1 * */3", want: cronSchedule{
+			minute: cronBits(0), hour: cronBits(9), dom: cronBits(1), month: cronBits(allMonths()...),
+			dow: cronBits(0, 3, 6), dowAny: true,
+		}},
+		{spec: "0 9 * *", fail: true},
+		{spec: "0 9 * * * *", fail: true},
+		{spec: "@yearly", fail: true},
+		{spec: "0 24 * * *", fail: true},
+		{spec: "0 9 * 13 *", fail: true},
+		{spec: "0 9 * * 8", fail: true},
+	}
+	for _, tt := range tests {
+		got, err := parseCron(tt.spec)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("parseCron(%q): ожидалась ошибка", tt.spec)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseCron(%q) = %+v, %v, ожидалось %+v", tt.spec, got, err, tt.want)
+		}
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	// 15.12.2025 — понедельник, 21.12.2025 — воскресенье
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2025, time.December, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		spec string
+		t    time.Time
+		want bool
+	}{
+		{"*/15 * * * *", at(15, 10, 45), true},
+		{"*/15 * * * *", at(15, 10, 44), false},
+		{"0 9 * * 1-5/2", at(17, 9, 0), true},
+		{"0 9 * * 1-5/2", at(16, 9, 0), false},
+		{"0 18 * * 7", at(21, 18, 0), true},
+		{"0 18 * * 0", at(21, 18, 0), true},
+		{"0 18 * * 7", at(20, 18, 0), false},
+		// День месяца и день недели ограничены оба: достаточно одного (ИЛИ)
+		{"0 9 1 * 1", at(15, 9, 0), true},
+		{"0 9 15 * 5", at(15, 9, 0), true},
+		{"0 9 1 * 5", at(15, 9, 0), false},
+		// Одно из полей — «*»: должны совпасть оба (И)
+		{"0 9 * * 5", at(15, 9, 0), false},
+		{"0 9 1 * *", at(15, 9, 0), false},
+		{"0 9 15 * *", at(15, 9, 0), true},
+		{"0 9 15 11 *", at(15, 9, 0), false},
+		// «*/2» ведет себя как «*»: тоже И
+		{"0 9 */2 * 1", at(15, 9, 0), true},
+		{"0 9 */2 * 1", at(17, 9, 0), false},
+		{"0 9 */2 * 1", at(22, 9, 0), false},
+		{"0 9 1 * */2", at(1, 9, 0), false},
+		{"0 9 1 * */2", at(16, 9, 0), false},
+		{"@daily", at(15, 0, 0), true},
+		{"@hourly", at(15, 13, 0), true},
+		{"@hourly", at(15, 13, 1), false},
+	}
+	for _, tt := range tests {
+		c, err := parseCron(tt.spec)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", tt.spec, err)
+		}
+		if got := c.matches(tt.t); got != tt.want {
+			t.Errorf("%q в %s: %v, ожидалось %v", tt.spec, tt.t.Format("02.01.2006 15:04 Mon"), got, tt.want)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// Пятница, 19.12.2025 10:07:30
+	after := time.Date(2025, time.December, 19, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2025, time.December, 19, 10, 15, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2025, time.December, 22, 9, 0, 0, 0, time.UTC)},
+		{"0 18 * * 7", time.Date(2025, time.December, 21, 18, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 9 1 * 6", time.Date(2025, time.December, 20, 9, 0, 0, 0, time.UTC)},
+		// Время отправки, совпадающее с after, не повторяется
+		{"7 10 * * *", time.Date(2025, time.December, 20, 10, 7, 0, 0, time.UTC)},
+		// 30 февраля не бывает
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		c, err := parseCron(tt.spec)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", tt.spec, err)
+		}
+		if got := c.next(after); !got.Equal(tt.want) {
+			t.Errorf("%q: следующий запуск %s, ожидалось %s", tt.spec, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Файл с настройками чатов по умолчанию
+const defaultStoragePath = "bot_data.json"
+
+// Настройки чатов в памяти; хранилище получает копию при каждом изменении.
+// Обновления разных чатов обрабатываются параллельно, поэтому доступ
+// только через функции ниже
+var (
+	chatSettingsMu sync.RWMutex
+	chatSettings   = make(map[int64]ChatSettings)
+	storage        Storage
+)
+
+// Загрузка сохраненных настроек при запуске бота
+func loadChatSettings(s Storage) error {
+	loaded, err := s.LoadAll()
+	if err != nil {
+		return err
+	}
+	chatSettingsMu.Lock()
+	defer chatSettingsMu.Unlock()
+	storage = s
+	chatSettings = loaded
+	return nil
+}
+
+// Копия настроек чата; для нового чата — пустые настройки
+func getChatSettings(chatID int64) ChatSettings {
+	chatSettingsMu.RLock()
+	defer chatSettingsMu.RUnlock()
+	return chatSettings[chatID].clone()
+}
+
+// Изменение настроек чата с сохранением в хранилище. Если сохранить не
+// удалось, изменение все равно действует до перезапуска бота
+func updateChatSettings(chatID int64, update func(*ChatSettings)) error {
+	chatSettingsMu.Lock()
+	defer chatSettingsMu.Unlock()
+	settings := chatSettings[chatID].clone()
+	update(&settings)
+	chatSettings[chatID] = settings
+	if storage == nil {
+		return nil
+	}
+	return storage.SaveChat(chatID, settings)
+}
+
+// Расписания рассылки всех подписанных чатов
+func scheduledChats() map[int64]ReportSchedule {
+	chatSettingsMu.RLock()
+	defer chatSettingsMu.RUnlock()
+	result := make(map[int64]ReportSchedule)
+	for chatID, settings := range chatSettings {
+		if settings.Schedule != nil {
+			result[chatID] = *settings.Schedule
+		}
+	}
+	return result
+}
+
+func getUserMode(chatID int64) (string, bool) {
+	mode := getChatSettings(chatID).Mode
+	return mode, mode != ""
+}
+
+func setUserMode(chatID int64, mode string) error {
+	return updateChatSettings(chatID, func(s *ChatSettings) {
+		s.Mode = mode
+	})
+}
+
+// Путь к файлу настроек можно задать переменной окружения storage_path
+func storagePath() string {
+	if path := os.Getenv("storage_path"); path != "" {
+		return path
+	}
+	return defaultStoragePath
+}
+
+// Настраиваемый порог отчета
+type thresholdSetting struct {
+	key          string
+	title        string
+	defaultValue float64
+	maxValue     float64
+}
+
+// Пороги всех отчетов. Значение по умолчанию можно переопределить переменной
+// окружения <key>_threshold, а каждый чат может задать свое через /settings
+var thresholdSettings = []thresholdSetting{
+	{key: "teacher_attendance", title: "Посещаемость преподавателей, %", defaultValue: 40, maxValue: 100},
+	{key: "checked_homework", title: "Проверка ДЗ преподавателями, %", defaultValue: 70, maxValue: 100},
+	{key: "submitted_homework", title: "Выполнение ДЗ студентами, %", defaultValue: 70, maxValue: 100},
+	{key: "student_classwork", title: "Классная работа: оценка ниже", defaultValue: 3, maxValue: 12},
+	{key: "student_homework", title: "Домашняя работа: оценка не выше", defaultValue: 1, maxValue: 12},
+	{key: "student_attendance", title: "Посещаемость студентов, %", defaultValue: 50, maxValue: 100},
+}
+
+func thresholdSettingByKey(key string) (thresholdSetting, bool) {
+	for _, t := range thresholdSettings {
+		if t.key == key {
+			return t, true
+		}
+	}
+	return thresholdSetting{}, false
+}
+
+// Значение порога из текста: «70», «70%», «65,5». Одно правило для
+// переменных окружения, /settings и --threshold: от 0 до maxValue
+func (t thresholdSetting) parse(text string) (float64, bool) {
+	text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "%"))
+	value, err := strconv.ParseFloat(strings.ReplaceAll(text, ",", "."), 64)
+	if err != nil || value < 0 || value > t.maxValue {
+		return 0, false
+	}
+	return value, true
+}
+
+// Порог по умолчанию с учетом переменной окружения
+func (t thresholdSetting) defaultThreshold() float64 {
+	value, ok := t.parse(os.Getenv(t.key + "_threshold"))
+	if !ok {
+		return t.defaultValue
+	}
+	return value
+}
+
+// Параметры обработки файла, зависящие от чата
+type processOptions struct {
+	thresholds map[string]float64
+	// Листы, выбранные пользователем; пустой список — все листы книги
+	sheets []string
+	// Названия колонок, указанные в чате командой /columns, по полям
+	columns map[string][]string
+	// Ход чтения листа: прочитано строк из total (0 — размер листа неизвестен)
+	progress func(sheet string, read, total int)
+	// Отчет по студентам отмечает и домашнюю, и классную работу, даже если
+	// студент уже попал в отчет по одной из них
+	allGrades bool
+}
+
+func chatProcessOptions(chatID int64) processOptions {
+	settings := getChatSettings(chatID)
+	return processOptions{thresholds: settings.Thresholds, columns: settings.Columns}
+}
+
+// Порог для чата, а если он не задан — значение по умолчанию
+func (o processOptions) threshold(key string) float64 {
+	if value, ok := o.thresholds[key]; ok {
+		return value
+	}
+	if t, ok := thresholdSettingByKey(key); ok {
+		return t.defaultThreshold()
+	}
+	return 0
+}
+
+// Порог без лишних нулей: 40, 37.5
+func formatThreshold(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// Ожидаемый ввод нового значения порога привязан к сообщению с вопросом,
+// как выбор типа файла и листов: в чате может быть открыто несколько вопросов
+var (
+	pendingSettingMu sync.Mutex
+	pendingSetting   = make(map[pendingKey]string)
+)
+
+// Текущие пороги чата и кнопки для их изменения
+func sendSettings(bot *tgbotapi.BotAPI, chatID int64) {
+	opts := chatProcessOptions(chatID)
+	var text strings.Builder
+	text.WriteString("⚙️ Пороги отчетов для этого чата:\n\n")
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, t := range thresholdSettings {
+		text.WriteString(fmt.Sprintf("%s: %s\n", t.title, formatThreshold(opts.threshold(t.key))))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(t.title, "settings_set_"+t.key),
+		))
+	}
+	text.WriteString("\nНажмите на порог, чтобы изменить его.")
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Сбросить по умолчанию", "settings_reset"),
+	))
+
+	msg := tgbotapi.NewMessage(chatID, text.String())
+	msg.ReplyMarkup = tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+	bot.Send(msg)
+}
+
+func handleSettingsCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	chatID := callback.Message.Chat.ID
+
+	if callback.Data == "settings_reset" {
+		err := updateChatSettings(chatID, func(s *ChatSettings) {
+			s.Thresholds = nil
+		})
+		if err != nil {
+			slog.Error("Не удалось сохранить настройки чата", "chat_id", chatID, "err", err)
+		}
+		bot.Request(tgbotapi.NewCallback(callback.ID, "Пороги сброшены"))
+		sendSettings(bot, chatID)
+		return
+	}
+
+	t, ok := thresholdSettingByKey(strings.TrimPrefix(callback.Data, "settings_set_"))
+	if !ok {
+		bot.Request(tgbotapi.NewCallback(callback.ID, "Неизвестная настройка"))
+		return
+	}
+	bot.Request(tgbotapi.NewCallback(callback.ID, t.title))
+
+	current := formatThreshold(chatProcessOptions(chatID).threshold(t.key))
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Введите новое значение для «%s» (сейчас %s, допустимо от 0 до %s):", t.title, current, formatThreshold(t.maxValue)))
+	// В группе ответ на вопрос отличает ввод значения от остальных сообщений
+	msg.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true}
+	sent, err := bot.Send(msg)
+	if err != nil {
+		slog.Error("Не удалось отправить вопрос о пороге", "chat_id", chatID, "err", err)
+		return
+	}
+	pendingSettingMu.Lock()
+	pendingSetting[pendingKey{chatID: chatID, messageID: sent.MessageID}] = t.key
+	pendingSettingMu.Unlock()
+}
+
+// Вопрос, на который отвечает сообщение. Ответ на конкретный вопрос
+// определяется по reply; в личном чате без reply берется последний вопрос
+func pendingSettingFor(msg *tgbotapi.Message) (pendingKey, string, bool) {
+	pendingSettingMu.Lock()
+	defer pendingSettingMu.Unlock()
+	if msg.ReplyToMessage != nil {
+		key := pendingKey{chatID: msg.Chat.ID, messageID: msg.ReplyToMessage.MessageID}
+		setting, ok := pendingSetting[key]
+		return key, setting, ok
+	}
+	if !msg.Chat.IsPrivate() {
+		return pendingKey{}, "", false
+	}
+	var last pendingKey
+	for key := range pendingSetting {
+		if key.chatID == msg.Chat.ID && key.messageID > last.messageID {
+			last = key
+		}
+	}
+	setting, ok := pendingSetting[last]
+	return last, setting, ok
+}
+
+// Обработка введенного значения порога. Возвращает false, если сообщение
+// не отвечает на вопрос о пороге
+func handleSettingInput(bot *tgbotapi.BotAPI, msg *tgbotapi.Message) bool {
+	chatID := msg.Chat.ID
+	pending, key, ok := pendingSettingFor(msg)
+	if !ok {
+		return false
+	}
+	t, _ := thresholdSettingByKey(key)
+
+	value, ok := t.parse(msg.Text)
+	if !ok {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Нужно число от 0 до %s. Попробуйте еще раз.", formatThreshold(t.maxValue))))
+		return true
+	}
+
+	pendingSettingMu.Lock()
+	delete(pendingSetting, pending)
+	pendingSettingMu.Unlock()
+	err := updateChatSettings(chatID, func(s *ChatSettings) {
+		if s.Thresholds == nil {
+			s.Thresholds = make(map[string]float64)
+		}
+		s.Thresholds[key] = value
+	})
+	if err != nil {
+		slog.Error("Не удалось сохранить настройки чата", "chat_id", chatID, "err", err)
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ «%s» теперь %s", t.title, formatThreshold(value))))
+	return true
+}
+
+// Отмена ожидания ввода, например когда пользователь отправил команду
+func cancelSettingInput(chatID int64) {
+	pendingSettingMu.Lock()
+	defer pendingSettingMu.Unlock()
+	for key := range pendingSetting {
+		if key.chatID == chatID {
+			delete(pendingSetting, key)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestThresholdParse(t *testing.T) {
+	percent, _ := thresholdSettingByKey("submitted_homework")
+	grade, _ := thresholdSettingByKey("student_classwork")
+	tests := []struct {
+		setting thresholdSetting
+		text    string
+		want    float64
+		ok      bool
+	}{
+		{percent, "70", 70, true},
+		{percent, " 65,5 % ", 65.5, true},
+		{percent, "0", 0, true},
+		{percent, "100", 100, true},
+		{percent, "101", 0, false},
+		{percent, "-1", 0, false},
+		{percent, "", 0, false},
+		{percent, "много", 0, false},
+		{grade, "12", 12, true},
+		{grade, "13", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := tt.setting.parse(tt.text)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s.parse(%q) = %v, %v, ожидалось %v, %v", tt.setting.key, tt.text, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	// Порог из окружения проверяется тем же правилом
+	t.Setenv("submitted_homework_threshold", "0")
+	if got := percent.defaultThreshold(); got != 0 {
+		t.Errorf("порог из окружения 0: %v", got)
+	}
+	t.Setenv("submitted_homework_threshold", "150")
+	if got := percent.defaultThreshold(); got != percent.defaultValue {
+		t.Errorf("порог из окружения 150: %v, ожидалось значение по умолчанию", got)
+	}
+}
+
+func TestPendingSettingFor(t *testing.T) {
+	const private, group = 42, -100
+	pendingSettingMu.Lock()
+	pendingSetting = map[pendingKey]string{
+		{chatID: private, messageID: 10}: "checked_homework",
+		{chatID: private, messageID: 12}: "student_attendance",
+		{chatID: group, messageID: 20}:   "teacher_attendance",
+		{chatID: group, messageID: 21}:   "submitted_homework",
+	}
+	pendingSettingMu.Unlock()
+	t.Cleanup(func() { cancelSettingInput(private); cancelSettingInput(group) })
+
+	message := func(chatID int64, chatType string, replyTo int) *tgbotapi.Message {
+		msg := &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: chatID, Type: chatType}, Text: "50"}
+		if replyTo != 0 {
+			msg.ReplyToMessage = &tgbotapi.Message{MessageID: replyTo}
+		}
+		return msg
+	}
+	tests := []struct {
+		msg  *tgbotapi.Message
+		want string
+		ok   bool
+	}{
+		// Ответ на конкретный вопрос меняет свой порог
+		{message(group, "supergroup", 20), "teacher_attendance", true},
+		{message(group, "supergroup", 21), "submitted_homework", true},
+		{message(private, "private", 10), "checked_homework", true},
+		// Обычное сообщение в группе не считается вводом порога
+		{message(group, "supergroup", 0), "", false},
+		{message(group, "supergroup", 99), "", false},
+		// В личном чате без ответа берется последний вопрос
+		{message(private, "private", 0), "student_attendance", true},
+	}
+	for _, tt := range tests {
+		_, got, ok := pendingSettingFor(tt.msg)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("чат %d, ответ на %v: %q, %v, ожидалось %q, %v", tt.msg.Chat.ID, tt.msg.ReplyToMessage, got, ok, tt.want, tt.ok)
+		}
+	}
+}
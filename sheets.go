@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Режимы выбора листов, настраиваются командой /sheets
+const (
+	sheetModeAll = "all"
+	sheetModeAsk = "ask"
+)
+
+// Обработка листов книги по отдельности. Пустые листы пропускаются, отчет
+// с одного листа возвращается как есть, с нескольких — объединяется
+// по разделам с именами листов. Листы без данных или без нужных колонок
+// (например, служебные) пропускаются, если подошел хотя бы один лист
+func processSheets(wb *workbook, opts processOptions, process func(rows *sheetReader, opts processOptions) (*Report, error)) (*Report, error) {
+	var sources []string
+	var reports []*Report
+	var skipped error
+	for _, sh := range wb.selectSheets(opts.sheets) {
+		report, err := processSheet(sh, opts, process)
+		if report == nil && err == nil {
+			continue
+		}
+		if err != nil && isSheetSkippable(err) {
+			if skipped == nil {
+				skipped = err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("лист «%s»: %w", sh.name, err)
+		}
+		sources = append(sources, fmt.Sprintf("Лист «%s»", sh.name))
+		reports = append(reports, report)
+	}
+	switch {
+	case len(reports) == 0 && skipped != nil:
+		return nil, skipped
+	case len(reports) == 0:
+		return nil, errEmptySheet
+	case len(reports) == 1:
+		return reports[0], nil
+	}
+	return mergeReports(sources, reports), nil
+}
+
+// Обработка одного листа с чтением строк потоком; для пустого листа
+// возвращает nil без ошибки
+func processSheet(sh sheet, opts processOptions, process func(rows *sheetReader, opts processOptions) (*Report, error)) (*Report, error) {
+	rows, err := sh.open()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.close()
+	if rows.blank() {
+		return nil, nil
+	}
+	if opts.progress != nil {
+		rows.progress = func(read int) { opts.progress(sh.name, read, rows.layout.rows) }
+	}
+	report, err := process(rows, opts)
+	if err == nil {
+		err = rows.err()
+	}
+	return report, err
+}
+
+// Лист без данных, см. sheetReader.blank. Объединенные ячейки на это не
+// влияют, поэтому лист открывается без них
+func (sh sheet) blank() bool {
+	rows, err := sh.openHead()
+	if err != nil {
+		// Ошибку чтения покажет обработка листа
+		return false
+	}
+	defer rows.close()
+	return rows.blank()
+}
+
+// Листы книги; если имена заданы — только они, в порядке книги
+func (wb *workbook) selectSheets(names []string) []sheet {
+	var selected []sheet
+	for _, sh := range wb.sheets {
+		if len(names) > 0 && !slices.Contains(names, sh.name) {
+			continue
+		}
+		selected = append(selected, sh)
+	}
+	return selected
+}
+
+// Режим выбора листов в чате: по умолчанию обрабатываются все листы
+func chatSheetMode(chatID int64) string {
+	if getChatSettings(chatID).Preferences["sheets"] == sheetModeAsk {
+		return sheetModeAsk
+	}
+	return sheetModeAll
+}
+
+func setChatSheetMode(chatID int64, mode string) error {
+	return updateChatSettings(chatID, func(s *ChatSettings) {
+		if s.Preferences == nil {
+			s.Preferences = make(map[string]string)
+		}
+		s.Preferences["sheets"] = mode
+	})
+}
+
+func sheetModeLabel(mode string) string {
+	if mode == sheetModeAsk {
+		return "спрашивать, какие листы обработать"
+	}
+	return "обрабатывать все листы"
+}
+
+// Команда /sheets: с аргументом all или ask сразу меняет режим, без него — показывает кнопки
+func handleSheetsCommand(bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	if arg := strings.ToLower(strings.TrimSpace(msg.CommandArguments())); arg != "" {
+		if arg != sheetModeAll && arg != sheetModeAsk {
+			bot.Send(tgbotapi.NewMessage(chatID, "Неизвестный режим. Доступны: all, ask"))
+			return
+		}
+		if err := setChatSheetMode(chatID, arg); err != nil {
+			slog.Error("Не удалось сохранить настройки чата", "chat_id", chatID, "err", err)
+		}
+		bot.Send(tgbotapi.NewMessage(chatID, "Листы книги: "+sheetModeLabel(arg)))
+		return
+	}
+
+	reply := tgbotapi.NewMessage(chatID, fmt.Sprintf("Сейчас для книг с несколькими листами: %s\nВыберите режим:", sheetModeLabel(chatSheetMode(chatID))))
+	reply.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Все листы", "sheets_"+sheetModeAll),
+		tgbotapi.NewInlineKeyboardButtonData("Выбирать листы", "sheets_"+sheetModeAsk),
+	))
+	bot.Send(reply)
+}
+
+func handleSheetsCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	chatID := callback.Message.Chat.ID
+	mode := strings.TrimPrefix(callback.Data, "sheets_")
+	if mode != sheetModeAll && mode != sheetModeAsk {
+		bot.Request(tgbotapi.NewCallback(callback.ID, "Неизвестный режим"))
+		return
+	}
+	if err := setChatSheetMode(chatID, mode); err != nil {
+		slog.Error("Не удалось сохранить настройки чата", "chat_id", chatID, "err", err)
+	}
+	bot.Request(tgbotapi.NewCallback(callback.ID, "Сохранено"))
+	bot.Send(tgbotapi.NewMessage(chatID, "Листы книги: "+sheetModeLabel(mode)))
+}
+
+// Непустые листы файла, из которых пользователь выбирает нужные
+func workbookSheetNames(filepath string) []string {
+	wb, err := openWorkbook(filepath)
+	if err != nil {
+		return nil
+	}
+	defer wb.Close()
+	var names []string
+	for _, sh := range wb.sheets {
+		if !sh.blank() {
+			names = append(names, sh.name)
+		}
+	}
+	return names
+}
+
+// Выбор листов для файла: имена листов и отметки. Кнопки ссылаются на лист
+// по номеру, так как имя может не поместиться в данные кнопки
+type sheetSelection struct {
+	file     pendingFile
+	names    []string
+	selected []bool
+}
+
+var (
+	pendingSheetsMu sync.Mutex
+	pendingSheets   = make(map[pendingKey]*sheetSelection)
+)
+
+// Вопрос пользователю, какие листы обработать; изначально отмечены все
+func askSheets(bot *tgbotapi.BotAPI, chatID int64, file pendingFile, names []string) {
+	sel := &sheetSelection{file: file, names: names, selected: make([]bool, len(names))}
+	for i := range sel.selected {
+		sel.selected[i] = true
+	}
+	msg := tgbotapi.NewMessage(chatID, "В книге несколько листов. Отметьте, какие обработать:")
+	msg.ReplyMarkup = sel.keyboard()
+	sent, err := bot.Send(msg)
+	if err != nil {
+		slog.Error("Не удалось отправить выбор листов", "chat_id", chatID, "err", err)
+		return
+	}
+	pendingSheetsMu.Lock()
+	pendingSheets[pendingKey{chatID: chatID, messageID: sent.MessageID}] = sel
+	pendingSheetsMu.Unlock()
+}
+
+func (sel *sheetSelection) keyboard() tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, name := range sel.names {
+		mark := "▫️ "
+		if sel.selected[i] {
+			mark = "✅ "
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(mark+name, "sheetsel_"+strconv.Itoa(i)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Обработать", "sheetsel_done"),
+	))
+	return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+func (sel *sheetSelection) selectedNames() []string {
+	var names []string
+	for i, name := range sel.names {
+		if sel.selected[i] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Отметка листа или запуск обработки выбранных листов
+func handleSheetSelectCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	chatID := callback.Message.Chat.ID
+	data := strings.TrimPrefix(callback.Data, "sheetsel_")
+
+	key := pendingKey{chatID: chatID, messageID: callback.Message.MessageID}
+	pendingSheetsMu.Lock()
+	sel, ok := pendingSheets[key]
+	if !ok {
+		pendingSheetsMu.Unlock()
+		bot.Request(tgbotapi.NewCallback(callback.ID, "Файл уже обработан, отправьте его еще раз"))
+		return
+	}
+
+	if data != "done" {
+		i, err := strconv.Atoi(data)
+		if err == nil && i >= 0 && i < len(sel.selected) {
+			sel.selected[i] = !sel.selected[i]
+		}
+		markup := sel.keyboard()
+		pendingSheetsMu.Unlock()
+		bot.Request(tgbotapi.NewCallback(callback.ID, ""))
+		bot.Send(tgbotapi.NewEditMessageReplyMarkup(chatID, callback.Message.MessageID, markup))
+		return
+	}
+
+	names := sel.selectedNames()
+	if len(names) == 0 {
+		pendingSheetsMu.Unlock()
+		bot.Request(tgbotapi.NewCallback(callback.ID, "Отметьте хотя бы один лист"))
+		return
+	}
+	delete(pendingSheets, key)
+	pendingSheetsMu.Unlock()
+
+	bot.Request(tgbotapi.NewCallback(callback.ID, fmt.Sprintf("Листов: %d", len(names))))
+	bot.Send(tgbotapi.NewDeleteMessage(chatID, callback.Message.MessageID))
+	file := sel.file
+	file.sheets = names
+	processDocument(bot, chatID, file)
+}
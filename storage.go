@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// Сохраняемые настройки чата
+type ChatSettings struct {
+	// Выбранный режим обработки (CallbackID обработчика)
+	Mode string `json:"mode,omitempty"`
+	// Пороговые значения отчетов
+	Thresholds map[string]float64 `json:"thresholds,omitempty"`
+	// Прочие предпочтения пользователя
+	Preferences map[string]string `json:"preferences,omitempty"`
+	// Названия колонок, указанные пользователем для полей отчетов
+	Columns map[string][]string `json:"columns,omitempty"`
+	// Плановая рассылка отчетов, nil — чат не подписан
+	Schedule *ReportSchedule `json:"schedule,omitempty"`
+}
+
+// Расписание рассылки: выражение cron, что отправлять (digest или report)
+// и кто настроил — рассылка идет, пока у него есть доступ
+type ReportSchedule struct {
+	Spec string `json:"spec"`
+	Kind string `json:"kind"`
+	By   int64  `json:"by,omitempty"`
+}
+
+// Хранилище настроек чатов. Реализация должна быть безопасна для
+// одновременного вызова из разных обработчиков
+type Storage interface {
+	// Все сохраненные настройки, вызывается при запуске бота
+	LoadAll() (map[int64]ChatSettings, error)
+	// Сохранение настроек одного чата
+	SaveChat(chatID int64, settings ChatSettings) error
+}
+
+// Хранилище в JSON-файле рядом с ботом. Файл перезаписывается целиком через
+// временный файл и переименование, поэтому при сбое не остается полузаписанным
+type jsonFileStorage struct {
+	mu    sync.Mutex
+	path  string
+	chats map[int64]ChatSettings
+}
+
+func newJSONFileStorage(path string) *jsonFileStorage {
+	return &jsonFileStorage{path: path, chats: make(map[int64]ChatSettings)}
+}
+
+func (s *jsonFileStorage) LoadAll() (map[int64]ChatSettings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return make(map[int64]ChatSettings), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	chats := make(map[int64]ChatSettings)
+	if err := json.Unmarshal(data, &chats); err != nil {
+		return nil, err
+	}
+	s.chats = chats
+
+	result := make(map[int64]ChatSettings, len(chats))
+	for chatID, settings := range chats {
+		result[chatID] = settings.clone()
+	}
+	return result, nil
+}
+
+func (s *jsonFileStorage) SaveChat(chatID int64, settings ChatSettings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.chats[chatID] = settings.clone()
+	data, err := json.MarshalIndent(s.chats, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// Запись во временный файл в том же каталоге и замена исходного
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Копия настроек, не разделяющая карты с оригиналом
+func (s ChatSettings) clone() ChatSettings {
+	c := s
+	c.Thresholds = maps.Clone(s.Thresholds)
+	c.Preferences = maps.Clone(s.Preferences)
+	if s.Columns != nil {
+		c.Columns = make(map[string][]string, len(s.Columns))
+		for field, names := range s.Columns {
+			c.Columns[field] = slices.Clone(names)
+		}
+	}
+	if s.Schedule != nil {
+		schedule := *s.Schedule
+		c.Schedule = &schedule
+	}
+	return c
+}
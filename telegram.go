@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+)
+
+// Повторы запросов к Telegram при временных сбоях: обрыв соединения,
+// ошибки сервера 5xx и ограничение частоты 429
+const (
+	telegramAttempts  = 3
+	telegramBaseDelay = time.Second
+	// Дольше ждать не имеет смысла: пользователь решит, что бот завис
+	telegramMaxDelay = 30 * time.Second
+)
+
+// HTTP-клиент для Bot API и скачивания файлов. Запросы повторяются при
+// временных сбоях, а итоговые ошибки Telegram записываются в журнал, так как
+// результаты bot.Send в обработчиках не проверяются
+type telegramClient struct {
+	client *http.Client
+}
+
+var telegramHTTP = &telegramClient{client: &http.Client{}}
+
+// Методы Bot API, которые только читают данные. После обрыва соединения
+// неизвестно, выполнил ли Telegram запрос, и повтор sendMessage или
+// sendDocument отправил бы пользователю второе сообщение
+var idempotentTelegramMethods = map[string]bool{
+	"getMe":          true,
+	"getUpdates":     true,
+	"getFile":        true,
+	"getWebhookInfo": true,
+}
+
+// Запрос можно повторить после обрыва соединения: чтение данных или
+// скачивание файла (GET)
+func idempotentRequest(req *http.Request) bool {
+	return req.Method == http.MethodGet || idempotentTelegramMethods[path.Base(req.URL.Path)]
+}
+
+func (c *telegramClient) Do(req *http.Request) (*http.Response, error) {
+	method := path.Base(req.URL.Path)
+	for attempt := 1; ; attempt++ {
+		resp, err := c.client.Do(req)
+		delay, retry := retryDelay(resp, err, attempt, idempotentRequest(req))
+		// Тело запроса с файлом читается из потока и повторно не отправляется
+		canRewind := req.Body == nil || req.GetBody != nil
+		if !retry || attempt >= telegramAttempts || !canRewind {
+			if err != nil {
+				metrics.countError("telegram_api")
+				return nil, redactURLError(err, method)
+			}
+			if resp.StatusCode >= 400 {
+				metrics.countError("telegram_api")
+				slog.Warn("Ошибка Telegram API", "method", method, "status", resp.StatusCode, "description", telegramDescription(resp))
+			}
+			return resp, nil
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		slog.Warn("Временный сбой Telegram API, повтор", "method", method, "attempt", attempt, "delay", delay)
+		select {
+		case <-req.Context().Done():
+			return nil, redactURLError(req.Context().Err(), method)
+		case <-time.After(delay):
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// Нужен ли повтор и через сколько: Telegram сообщает время ожидания при 429
+// в заголовке Retry-After и в поле parameters.retry_after ответа. После
+// ошибки соединения повторяются только запросы, которые можно выполнить
+// дважды, и запросы, которые не ушли, так как соединение не установилось
+func retryDelay(resp *http.Response, err error, attempt int, idempotent bool) (time.Duration, bool) {
+	backoff := telegramBaseDelay << (attempt - 1)
+	if err != nil {
+		return backoff, idempotent || isDialError(err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		seconds, convErr := strconv.Atoi(resp.Header.Get("Retry-After"))
+		if convErr != nil {
+			seconds = telegramResponse(resp).Parameters.RetryAfter
+		}
+		if seconds > 0 {
+			backoff = time.Duration(seconds) * time.Second
+		}
+		return min(backoff, telegramMaxDelay), true
+	case resp.StatusCode >= 500:
+		return backoff, true
+	}
+	return 0, false
+}
+
+// Соединение не установлено (в том числе не найден адрес сервера), значит
+// запрос точно не дошел до Telegram
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// Ответ Bot API с ошибкой; тело ответа остается доступным для tgbotapi
+type telegramError struct {
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+func telegramResponse(resp *http.Response) telegramError {
+	var result telegramError
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	if err == nil {
+		json.Unmarshal(data, &result)
+	}
+	return result
+}
+
+func telegramDescription(resp *http.Response) string {
+	return telegramResponse(resp).Description
+}
+
+// В адресе запроса к Bot API есть токен бота, в журнал он попасть не должен
+func redactURLError(err error, method string) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		return &url.Error{Op: urlErr.Op, URL: method, Err: urlErr.Err}
+	}
+	return err
+}
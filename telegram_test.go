@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRetryDelay(t *testing.T) {
+	// Обрыв уже установленного соединения и ошибка подключения
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+	dial := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	status := func(code int, header, body string) *http.Response {
+		resp := &http.Response{StatusCode: code, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}
+		if header != "" {
+			resp.Header.Set("Retry-After", header)
+		}
+		return resp
+	}
+	tests := []struct {
+		name       string
+		resp       *http.Response
+		err        error
+		idempotent bool
+		attempt    int
+		retry      bool
+		delay      int
+	}{
+		{name: "обрыв при sendMessage", err: reset, attempt: 1},
+		{name: "обрыв при getFile", err: reset, idempotent: true, attempt: 2, retry: true, delay: 2},
+		{name: "нет соединения при sendMessage", err: dial, attempt: 1, retry: true, delay: 1},
+		{name: "429 с Retry-After", resp: status(429, "5", ""), attempt: 1, retry: true, delay: 5},
+		{name: "429 с retry_after в ответе", resp: status(429, "", `{"ok":false,"parameters":{"retry_after":3}}`), attempt: 1, retry: true, delay: 3},
+		{name: "429 дольше предела", resp: status(429, "600", ""), attempt: 1, retry: true, delay: 30},
+		{name: "502 при sendDocument", resp: status(502, "", ""), attempt: 2, retry: true, delay: 2},
+		{name: "400", resp: status(400, "", ""), idempotent: true, attempt: 1},
+	}
+	for _, tt := range tests {
+		delay, retry := retryDelay(tt.resp, tt.err, tt.attempt, tt.idempotent)
+		if retry != tt.retry || (retry && delay.Seconds() != float64(tt.delay)) {
+			t.Errorf("%s: повтор %v через %s, ожидалось %v через %dс", tt.name, retry, delay, tt.retry, tt.delay)
+		}
+	}
+}
+
+func TestIdempotentRequest(t *testing.T) {
+	tests := []struct {
+		method, url string
+		want        bool
+	}{
+		{http.MethodPost, "https://api.telegram.org/botTOKEN/getFile", true},
+		{http.MethodPost, "https://api.telegram.org/botTOKEN/getUpdates", true},
+		{http.MethodGet, "https://api.telegram.org/file/botTOKEN/documents/file_1.xlsx", true},
+		{http.MethodPost, "https://api.telegram.org/botTOKEN/sendMessage", false},
+		{http.MethodPost, "https://api.telegram.org/botTOKEN/sendDocument", false},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, tt.url, nil)
+		if got := idempotentRequest(req); got != tt.want {
+			t.Errorf("%s %s: %v, ожидалось %v", tt.method, tt.url, got, tt.want)
+		}
+	}
+}
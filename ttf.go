@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// Минимальный разбор шрифта TrueType для встраивания в PDF: таблица
+// символов (cmap), ширины глифов (hmtx) и метрики для описания шрифта
+type ttfFont struct {
+	data        []byte
+	unitsPerEm  float64
+	ascent      int16
+	descent     int16
+	capHeight   int16
+	bbox        [4]int16
+	italicAngle float64
+	advances    []uint16
+	glyphs      map[rune]uint16
+}
+
+func loadTTF(path string) (*ttfFont, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	font, err := parseTTF(data)
+	if err != nil {
+		return nil, fmt.Errorf("шрифт %s: %w", path, err)
+	}
+	return font, nil
+}
+
+func parseTTF(data []byte) (*ttfFont, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("файл слишком короткий")
+	}
+	switch binary.BigEndian.Uint32(data) {
+	case 0x00010000, 0x74727565: // TrueType, 'true'
+	case 0x4F54544F: // 'OTTO'
+		return nil, fmt.Errorf("шрифты OpenType/CFF не поддерживаются, нужен TrueType (.ttf)")
+	case 0x74746366: // 'ttcf'
+		return nil, fmt.Errorf("коллекции шрифтов (.ttc) не поддерживаются, нужен отдельный .ttf")
+	default:
+		return nil, fmt.Errorf("неизвестный формат шрифта")
+	}
+
+	tables := make(map[string][]byte)
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < numTables; i++ {
+		rec := 12 + i*16
+		if rec+16 > len(data) {
+			return nil, fmt.Errorf("повреждена таблица каталога")
+		}
+		tag := string(data[rec : rec+4])
+		offset := int(binary.BigEndian.Uint32(data[rec+8:]))
+		length := int(binary.BigEndian.Uint32(data[rec+12:]))
+		if offset < 0 || length < 0 || offset+length > len(data) {
+			return nil, fmt.Errorf("таблица %s выходит за пределы файла", tag)
+		}
+		tables[tag] = data[offset : offset+length]
+	}
+	for _, tag := range []string{"head", "hhea", "maxp", "hmtx", "cmap"} {
+		if tables[tag] == nil {
+			return nil, fmt.Errorf("нет таблицы %s", tag)
+		}
+	}
+
+	f := &ttfFont{data: data}
+	head, hhea, maxp := tables["head"], tables["hhea"], tables["maxp"]
+	if len(head) < 54 || len(hhea) < 36 || len(maxp) < 6 {
+		return nil, fmt.Errorf("повреждены заголовки шрифта")
+	}
+	f.unitsPerEm = float64(binary.BigEndian.Uint16(head[18:]))
+	if f.unitsPerEm == 0 {
+		return nil, fmt.Errorf("некорректный размер em")
+	}
+	for i := range f.bbox {
+		f.bbox[i] = int16(binary.BigEndian.Uint16(head[36+i*2:]))
+	}
+	f.ascent = int16(binary.BigEndian.Uint16(hhea[4:]))
+	f.descent = int16(binary.BigEndian.Uint16(hhea[6:]))
+	f.capHeight = f.ascent
+	if os2 := tables["OS/2"]; len(os2) >= 90 && binary.BigEndian.Uint16(os2) >= 2 {
+		f.capHeight = int16(binary.BigEndian.Uint16(os2[88:]))
+	}
+	if post := tables["post"]; len(post) >= 8 {
+		f.italicAngle = float64(int32(binary.BigEndian.Uint32(post[4:]))) / 65536
+	}
+
+	// Ширины: после numberOfHMetrics все глифы имеют ширину последнего
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	numMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	hmtx := tables["hmtx"]
+	if numMetrics == 0 || len(hmtx) < numMetrics*4 {
+		return nil, fmt.Errorf("повреждена таблица hmtx")
+	}
+	f.advances = make([]uint16, numGlyphs)
+	for i := range f.advances {
+		m := min(i, numMetrics-1)
+		f.advances[i] = binary.BigEndian.Uint16(hmtx[m*4:])
+	}
+
+	glyphs, err := parseCmap(tables["cmap"])
+	if err != nil {
+		return nil, err
+	}
+	f.glyphs = glyphs
+	return f, nil
+}
+
+// Таблица символов: предпочтительно полный Unicode (формат 12), иначе BMP (формат 4)
+func parseCmap(cmap []byte) (map[rune]uint16, error) {
+	if len(cmap) < 4 {
+		return nil, fmt.Errorf("повреждена таблица cmap")
+	}
+	var format4, format12 []byte
+	numTables := int(binary.BigEndian.Uint16(cmap[2:]))
+	for i := 0; i < numTables; i++ {
+		rec := 4 + i*8
+		if rec+8 > len(cmap) {
+			break
+		}
+		platform := binary.BigEndian.Uint16(cmap[rec:])
+		encoding := binary.BigEndian.Uint16(cmap[rec+2:])
+		offset := int(binary.BigEndian.Uint32(cmap[rec+4:]))
+		if offset+2 > len(cmap) {
+			continue
+		}
+		sub := cmap[offset:]
+		unicode := platform == 0 || (platform == 3 && (encoding == 1 || encoding == 10))
+		if !unicode {
+			continue
+		}
+		switch binary.BigEndian.Uint16(sub) {
+		case 4:
+			format4 = sub
+		case 12:
+			format12 = sub
+		}
+	}
+
+	glyphs := make(map[rune]uint16)
+	switch {
+	case format12 != nil && len(format12) >= 16:
+		groups := int(binary.BigEndian.Uint32(format12[12:]))
+		for g := 0; g < groups && 16+g*12+12 <= len(format12); g++ {
+			rec := format12[16+g*12:]
+			start := binary.BigEndian.Uint32(rec)
+			end := binary.BigEndian.Uint32(rec[4:])
+			gid := binary.BigEndian.Uint32(rec[8:])
+			for c := start; c <= end && c <= 0x10FFFF; c++ {
+				glyphs[rune(c)] = uint16(gid + c - start)
+			}
+		}
+	case format4 != nil && len(format4) >= 14:
+		segCount := int(binary.BigEndian.Uint16(format4[6:])) / 2
+		endCodes := 14
+		startCodes := endCodes + segCount*2 + 2
+		deltas := startCodes + segCount*2
+		rangeOffsets := deltas + segCount*2
+		if rangeOffsets+segCount*2 > len(format4) {
+			return nil, fmt.Errorf("повреждена таблица cmap")
+		}
+		for s := 0; s < segCount; s++ {
+			end := int(binary.BigEndian.Uint16(format4[endCodes+s*2:]))
+			start := int(binary.BigEndian.Uint16(format4[startCodes+s*2:]))
+			delta := int(binary.BigEndian.Uint16(format4[deltas+s*2:]))
+			rangeOffsetPos := rangeOffsets + s*2
+			rangeOffset := int(binary.BigEndian.Uint16(format4[rangeOffsetPos:]))
+			for c := start; c <= end && c != 0xFFFF; c++ {
+				var gid int
+				if rangeOffset == 0 {
+					gid = (c + delta) & 0xFFFF
+				} else {
+					pos := rangeOffsetPos + rangeOffset + (c-start)*2
+					if pos+2 > len(format4) {
+						continue
+					}
+					gid = int(binary.BigEndian.Uint16(format4[pos:]))
+					if gid != 0 {
+						gid = (gid + delta) & 0xFFFF
+					}
+				}
+				if gid != 0 {
+					glyphs[rune(c)] = uint16(gid)
+				}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("в шрифте нет таблицы символов Unicode")
+	}
+	return glyphs, nil
+}
+
+// Номер глифа символа; 0 — символа в шрифте нет
+func (f *ttfFont) glyph(r rune) uint16 {
+	return f.glyphs[r]
+}
+
+// Ширина глифа в тысячных долях кегля, как ее ожидает PDF
+func (f *ttfFont) glyphWidth(gid uint16) float64 {
+	if int(gid) >= len(f.advances) {
+		return 0
+	}
+	return float64(f.advances[gid]) * 1000 / f.unitsPerEm
+}
+
+// Ширина строки в пунктах при заданном кегле; символы без глифа не учитываются
+func (f *ttfFont) textWidth(text string, size float64) float64 {
+	var w float64
+	for _, r := range text {
+		if gid := f.glyph(r); gid != 0 {
+			w += f.glyphWidth(gid)
+		}
+	}
+	return w * size / 1000
+}
+
+// Масштабирование метрик шрифта в систему единиц PDF (1000 на em)
+func (f *ttfFont) scale(v int16) int {
+	return int(float64(v) * 1000 / f.unitsPerEm)
+}
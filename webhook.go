@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Адрес HTTP-сервера вебхука по умолчанию и путь проверки работоспособности
+const (
+	defaultWebhookListen = ":8080"
+	healthPath           = "/healthz"
+	// Сколько ждать завершения запросов к серверу при остановке бота
+	webhookShutdownTimeout = 10 * time.Second
+)
+
+// Заголовок, в котором Telegram передает секрет, заданный при установке вебхука
+const webhookSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// Допустимый секрет вебхука по правилам Bot API
+var webhookSecretPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// Настройки вебхука из .env. Режим включается переменной webhook_url —
+// публичным адресом, на который Telegram будет присылать обновления
+type webhookConfig struct {
+	url    *url.URL
+	listen string
+	path   string
+	secret string
+	// Сертификат и ключ для HTTPS; без них сервер работает по HTTP,
+	// например за обратным прокси, который сам принимает HTTPS
+	certFile, keyFile string
+}
+
+func loadWebhookConfig() (*webhookConfig, error) {
+	raw := os.Getenv("webhook_url")
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("webhook_url должен быть адресом вида https://example.com/path")
+	}
+	c := &webhookConfig{
+		url:      u,
+		listen:   os.Getenv("webhook_listen"),
+		path:     os.Getenv("webhook_path"),
+		secret:   os.Getenv("webhook_secret"),
+		certFile: os.Getenv("webhook_cert"),
+		keyFile:  os.Getenv("webhook_key"),
+	}
+	if c.listen == "" {
+		c.listen = defaultWebhookListen
+	}
+	// Прокси может переписывать путь, поэтому локальный путь задается отдельно
+	if c.path == "" {
+		c.path = u.Path
+	}
+	if c.path == "" {
+		c.path = "/"
+	}
+	// Путь становится шаблоном ServeMux: недопустимый или уже занятый
+	// шаблон остановил бы бота паникой при запуске сервера
+	if !strings.HasPrefix(c.path, "/") || strings.ContainsAny(c.path, " \t{}") {
+		return nil, fmt.Errorf("webhook_path должен начинаться с / и не содержать пробелов и фигурных скобок")
+	}
+	if c.path == healthPath || c.path == metricsPath {
+		return nil, fmt.Errorf("webhook_path %s занят служебным адресом, выберите другой путь", c.path)
+	}
+	if (c.certFile == "") != (c.keyFile == "") {
+		return nil, fmt.Errorf("для HTTPS нужны и webhook_cert, и webhook_key")
+	}
+	// Без секрета кто угодно, узнавший адрес, мог бы прислать поддельное
+	// обновление от имени администратора. Если секрет не задан, он
+	// создается при запуске и передается Telegram при установке вебхука
+	if c.secret == "" {
+		c.secret = randomWebhookSecret()
+	} else if !webhookSecretPattern.MatchString(c.secret) {
+		return nil, fmt.Errorf("webhook_secret может содержать только латинские буквы, цифры, _ и - (до 256 символов)")
+	}
+	return c, nil
+}
+
+func randomWebhookSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Прием обновлений через вебхук: регистрация адреса в Telegram и
+// HTTP-сервер, который передает обновления в канал. Возвращаемая функция
+// останавливает сервер
+func listenWebhook(bot *tgbotapi.BotAPI, c *webhookConfig) (tgbotapi.UpdatesChannel, func(), error) {
+	// Порт занимается до регистрации вебхука, чтобы ошибка запуска не
+	// потерялась в горутине сервера
+	listener, err := net.Listen("tcp", c.listen)
+	if err != nil {
+		return nil, nil, err
+	}
+	params := tgbotapi.Params{"url": c.url.String(), "secret_token": c.secret}
+	if _, err := bot.MakeRequest("setWebhook", params); err != nil {
+		listener.Close()
+		return nil, nil, fmt.Errorf("не удалось установить вебхук: %w", err)
+	}
+
+	updates := make(chan tgbotapi.Update, bot.Buffer)
+	done := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc(healthPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc(c.path, func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(webhookSecretHeader)), []byte(c.secret)) != 1 {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		update, err := bot.HandleUpdate(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		select {
+		case updates <- *update:
+		case <-done:
+			// Бот останавливается: Telegram пришлет обновление повторно
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		}
+	})
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		var err error
+		if c.certFile != "" {
+			err = server.ServeTLS(listener, c.certFile, c.keyFile)
+		} else {
+			err = server.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			slog.Error("Ошибка сервера вебхука", "err", err)
+		}
+	}()
+	slog.Info("Вебхук установлен", "url", c.url.Redacted(), "listen", c.listen, "path", c.path)
+
+	stop := func() {
+		close(done)
+		ctx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Error("Не удалось остановить сервер вебхука", "err", err)
+		}
+	}
+	return updates, stop, nil
+}
+
+// Адрес Bot API: для проверки с локальным тестовым сервером его можно
+// заменить переменной telegram_api_endpoint (формат как у tgbotapi.APIEndpoint)
+func telegramAPIEndpoint() string {
+	if endpoint := os.Getenv("telegram_api_endpoint"); endpoint != "" {
+		return endpoint
+	}
+	return tgbotapi.APIEndpoint
+}
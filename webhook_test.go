@@ -0,0 +1,167 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Поддельный Bot API: отвечает на getMe и запоминает параметры setWebhook
+func fakeTelegram(t *testing.T) (*tgbotapi.BotAPI, func() url.Values) {
+	var mu sync.Mutex
+	var webhook url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/getMe"):
+			w.Write([]byte(`{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"bot","username":"test_bot"}}`))
+		case strings.HasSuffix(r.URL.Path, "/setWebhook"):
+			mu.Lock()
+			webhook = r.PostForm
+			mu.Unlock()
+			w.Write([]byte(`{"ok":true,"result":true}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	bot, err := tgbotapi.NewBotAPIWithClient("token", server.URL+"/bot%s/%s", server.Client())
+	if err != nil {
+		t.Fatalf("NewBotAPIWithClient: %v", err)
+	}
+	return bot, func() url.Values {
+		mu.Lock()
+		defer mu.Unlock()
+		return webhook
+	}
+}
+
+// Свободный локальный адрес для сервера вебхука
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestListenWebhook(t *testing.T) {
+	bot, webhook := fakeTelegram(t)
+	u, _ := url.Parse("https://example.com/hook")
+	c := &webhookConfig{url: u, listen: freeAddr(t), path: "/hook", secret: "s3cret"}
+	updates, stop, err := listenWebhook(bot, c)
+	if err != nil {
+		t.Fatalf("listenWebhook: %v", err)
+	}
+	defer stop()
+
+	if got := webhook(); got.Get("url") != "https://example.com/hook" || got.Get("secret_token") != "s3cret" {
+		t.Errorf("параметры setWebhook: %v", got)
+	}
+
+	base := "http://" + c.listen
+	post := func(secret string) int {
+		body := `{"update_id":7,"message":{"message_id":1,"date":0,"chat":{"id":42,"type":"private"},"text":"/start"}}`
+		req, _ := http.NewRequest(http.MethodPost, base+"/hook", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if secret != "" {
+			req.Header.Set(webhookSecretHeader, secret)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST /hook: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	for _, secret := range []string{"", "wrong"} {
+		if code := post(secret); code != http.StatusForbidden {
+			t.Errorf("секрет %q: код %d, ожидался 403", secret, code)
+		}
+	}
+	select {
+	case update := <-updates:
+		t.Fatalf("обновление без верного секрета передано боту: %+v", update)
+	default:
+	}
+
+	if code := post("s3cret"); code != http.StatusOK {
+		t.Fatalf("верный секрет: код %d, ожидался 200", code)
+	}
+	select {
+	case update := <-updates:
+		if update.UpdateID != 7 || update.Message == nil || update.Message.Text != "/start" {
+			t.Errorf("обновление: %+v", update)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("обновление не передано боту")
+	}
+
+	resp, err := http.Get(base + healthPath)
+	if err != nil {
+		t.Fatalf("GET %s: %v", healthPath, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET %s: код %d, ожидался 200", healthPath, resp.StatusCode)
+	}
+}
+
+func TestLoadWebhookConfigSecret(t *testing.T) {
+	t.Setenv("webhook_url", "https://example.com/hook")
+
+	t.Setenv("webhook_secret", "")
+	c, err := loadWebhookConfig()
+	if err != nil {
+		t.Fatalf("loadWebhookConfig: %v", err)
+	}
+	if !webhookSecretPattern.MatchString(c.secret) {
+		t.Errorf("созданный секрет %q не подходит для Bot API", c.secret)
+	}
+
+	t.Setenv("webhook_secret", "not a secret!")
+	if _, err := loadWebhookConfig(); err == nil {
+		t.Error("секрет с недопустимыми символами принят")
+	}
+}
+
+func TestLoadWebhookConfigPath(t *testing.T) {
+	tests := []struct {
+		url, path string
+		want      string
+		fail      bool
+	}{
+		{url: "https://example.com/hook", want: "/hook"},
+		{url: "https://example.com", want: "/"},
+		{url: "https://example.com/hook", path: "/bot", want: "/bot"},
+		// Служебные адреса и недопустимые шаблоны ServeMux
+		{url: "https://example.com/healthz", fail: true},
+		{url: "https://example.com/hook", path: "/healthz", fail: true},
+		{url: "https://example.com/hook", path: "/metrics", fail: true},
+		{url: "https://example.com/hook", path: "hook", fail: true},
+		{url: "https://example.com/hook", path: "/{id}", fail: true},
+	}
+	for _, tt := range tests {
+		t.Setenv("webhook_url", tt.url)
+		t.Setenv("webhook_path", tt.path)
+		c, err := loadWebhookConfig()
+		if tt.fail {
+			if err == nil {
+				t.Errorf("%s с путем %q: ожидалась ошибка", tt.url, tt.path)
+			}
+			continue
+		}
+		if err != nil || c.path != tt.want {
+			t.Errorf("%s с путем %q: %+v, %v, ожидался путь %s", tt.url, tt.path, c, err, tt.want)
+		}
+	}
+}
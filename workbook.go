@@ -0,0 +1,432 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	excelize "github.com/xuri/excelize/v2"
+)
+
+// Лист книги: имя и построчное чтение ячеек в текстовом виде
+type sheet struct {
+	name string
+	// Начинает чтение строк листа с первой строки
+	open func() (*sheetReader, error)
+	// То же без объединенных ячеек и размера листа, для тех, кому нужны
+	// только первые строки как есть (определение типа, поиск пустых листов).
+	// В .xlsx их поиск — отдельный проход по всему XML листа
+	openHead func() (*sheetReader, error)
+}
+
+// Книга, прочитанная из .xlsx или старого .xls, в едином виде для всех
+// обработчиков. Строки листов читаются по мере обработки, поэтому книгу
+// нужно закрыть
+type workbook struct {
+	sheets []sheet
+	close  func() error
+}
+
+func (wb *workbook) Close() error {
+	if wb.close == nil {
+		return nil
+	}
+	return wb.close()
+}
+
+// Сигнатуры форматов: OOXML — это zip-архив, BIFF8 лежит в OLE-контейнере
+var (
+	zipSignature = []byte{'P', 'K', 0x03, 0x04}
+	oleSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+)
+
+// Открытие книги с определением формата по содержимому, а не по расширению:
+// выгрузки часто приходят как .xls, хотя внутри лежит .xlsx, и наоборот
+func openWorkbook(filepath string) (*workbook, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	head := make([]byte, len(oleSignature))
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("не удалось прочитать файл: %w", err)
+	}
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, oleSignature):
+		return readXLS(file)
+	case bytes.HasPrefix(head, zipSignature):
+		return readXLSX(filepath)
+	default:
+		return nil, &unsupportedFormatError{reason: "это не книга Excel"}
+	}
+}
+
+func readXLSX(filepath string) (*workbook, error) {
+	file, err := excelize.OpenFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := zip.OpenReader(filepath)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	paths, err := xlsxSheetPaths(&archive.Reader)
+	if err != nil {
+		archive.Close()
+		file.Close()
+		return nil, err
+	}
+
+	wb := &workbook{close: func() error {
+		archive.Close()
+		return file.Close()
+	}}
+	for _, name := range file.GetSheetList() {
+		openRows := func(scan bool) (*sheetReader, error) {
+			var layout sheetLayout
+			if sheetPath, ok := paths[name]; ok && scan {
+				scanned, err := scanXLSXSheet(&archive.Reader, sheetPath)
+				if err != nil {
+					return nil, err
+				}
+				layout = scanned
+			}
+			rows, err := file.Rows(name)
+			if err != nil {
+				return nil, err
+			}
+			return newSheetReader(rows, layout), nil
+		}
+		wb.sheets = append(wb.sheets, sheet{
+			name:     name,
+			open:     func() (*sheetReader, error) { return openRows(true) },
+			openHead: func() (*sheetReader, error) { return openRows(false) },
+		})
+	}
+	return wb, nil
+}
+
+// Пути XML листов внутри архива .xlsx по именам листов: книга ссылается
+// на листы через связи из xl/_rels/workbook.xml.rels
+func xlsxSheetPaths(archive *zip.Reader) (map[string]string, error) {
+	var book struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			ID   string `xml:"id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := readXMLPart(archive, "xl/workbook.xml", &book); err != nil {
+		return nil, err
+	}
+	if err := readXMLPart(archive, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+
+	targets := make(map[string]string)
+	for _, rel := range rels.Relationships {
+		if strings.HasPrefix(rel.Target, "/") {
+			targets[rel.ID] = strings.TrimPrefix(rel.Target, "/")
+		} else {
+			targets[rel.ID] = path.Join("xl", rel.Target)
+		}
+	}
+	paths := make(map[string]string)
+	for _, s := range book.Sheets {
+		if target, ok := targets[s.ID]; ok {
+			paths[s.Name] = target
+		}
+	}
+	return paths, nil
+}
+
+func readXMLPart(archive *zip.Reader, name string, v any) error {
+	f, err := archive.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return xml.NewDecoder(f).Decode(v)
+}
+
+// Размер листа и объединенные ячейки, известные до чтения строк. Размер
+// нулевой, если он не записан в файле
+type sheetLayout struct {
+	rows, cols int
+	merges     []cellRange
+}
+
+// Размер листа и объединенные ячейки из XML листа .xlsx. excelize отдает
+// объединенные ячейки, только загрузив в память весь лист, поэтому XML
+// просматривается потоком: размер записан перед данными, объединения — после
+func scanXLSXSheet(archive *zip.Reader, name string) (sheetLayout, error) {
+	var layout sheetLayout
+	f, err := archive.Open(name)
+	if err != nil {
+		return layout, err
+	}
+	defer f.Close()
+
+	decoder := xml.NewDecoder(f)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return layout, nil
+		}
+		if err != nil {
+			return layout, fmt.Errorf("не удалось прочитать лист: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "dimension":
+			// Вместо размера бывает записана одна ячейка A1, тогда размер неизвестен
+			if ref := xmlAttr(start, "ref"); strings.Contains(ref, ":") {
+				if r, ok := parseCellRange(ref); ok {
+					layout.rows, layout.cols = r.lastRow+1, r.lastCol+1
+				}
+			}
+		case "sheetData":
+			if err := decoder.Skip(); err != nil {
+				return layout, fmt.Errorf("не удалось прочитать лист: %w", err)
+			}
+		case "mergeCell":
+			if r, ok := parseCellRange(xmlAttr(start, "ref")); ok {
+				layout.merges = append(layout.merges, r)
+			}
+		}
+	}
+}
+
+func xmlAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// Диапазон вида A1:C3 или одна ячейка A1
+func parseCellRange(ref string) (cellRange, bool) {
+	first, last, isRange := strings.Cut(ref, ":")
+	if !isRange {
+		last = first
+	}
+	firstCol, firstRow, err1 := excelize.CellNameToCoordinates(first)
+	lastCol, lastRow, err2 := excelize.CellNameToCoordinates(last)
+	if err1 != nil || err2 != nil {
+		return cellRange{}, false
+	}
+	return cellRange{firstRow: firstRow - 1, lastRow: lastRow - 1, firstCol: firstCol - 1, lastCol: lastCol - 1}, true
+}
+
+// Диапазон объединенных ячеек, номера строк и колонок с нуля
+type cellRange struct {
+	firstRow, lastRow int
+	firstCol, lastCol int
+}
+
+// Источник строк листа; ему соответствует итератор строк excelize
+type rowIterator interface {
+	Next() bool
+	Columns(opts ...excelize.Options) ([]string, error)
+	Error() error
+	Close() error
+}
+
+// Строки листа .xls: книга этого формата целиком читается в память
+type sliceRows struct {
+	rows [][]string
+	pos  int
+}
+
+func (s *sliceRows) Next() bool {
+	if s.pos >= len(s.rows) {
+		return false
+	}
+	s.pos++
+	return true
+}
+
+func (s *sliceRows) Columns(...excelize.Options) ([]string, error) { return s.rows[s.pos-1], nil }
+func (s *sliceRows) Error() error                                  { return nil }
+func (s *sliceRows) Close() error                                  { return nil }
+
+// Сколько первых строк листа читается заранее: в них ищется заголовок
+// и строки, повторяющие его под объединенными ячейками
+const sheetHeadRows = 2 * headerScanRows
+
+// Построчное чтение листа, общее для всех обработчиков. Первые строки
+// доступны сразу для поиска заголовка, остальные читаются по одной, так что
+// большой лист не загружается в память целиком
+type sheetReader struct {
+	source rowIterator
+	layout sheetLayout
+	// Объединения, которые начинаются ниже прочитанных строк, по возрастанию
+	// первой строки, и объединения, которые захватывают текущую строку
+	pending []cellRange
+	active  []mergedCell
+	width   int
+	// Строки, прочитанные заранее через head, но еще не отданные next
+	buffer  [][]string
+	started bool
+	current []string
+	read    int
+	done    bool
+	fail    error
+	// Вызывается после каждой строки с числом прочитанных строк
+	progress func(read int)
+}
+
+type mergedCell struct {
+	cellRange
+	value string
+}
+
+func newSheetReader(source rowIterator, layout sheetLayout) *sheetReader {
+	pending := append([]cellRange(nil), layout.merges...)
+	sort.SliceStable(pending, func(i, j int) bool { return pending[i].firstRow < pending[j].firstRow })
+	return &sheetReader{source: source, layout: layout, pending: pending, width: layout.cols}
+}
+
+// Первые строки листа (не больше sheetHeadRows) до начала чтения через next.
+// Объединенные ячейки в них разворачиваются, когда прочитаны все эти строки:
+// ширина листа к этому времени известна хотя бы по заголовку
+func (r *sheetReader) head() [][]string {
+	if r.started {
+		return r.buffer
+	}
+	r.started = true
+	for len(r.buffer) < sheetHeadRows {
+		row, ok := r.fetch()
+		if !ok {
+			break
+		}
+		r.buffer = append(r.buffer, row)
+	}
+	for i, row := range r.buffer {
+		r.buffer[i] = r.fillMergedCells(i, row)
+	}
+	return r.buffer
+}
+
+// Лист без данных: в первых строках нет ни одной ячейки. Заголовок ищется
+// только в них, поэтому обработать такой лист все равно нельзя
+func (r *sheetReader) blank() bool {
+	for _, row := range r.head() {
+		if len(row) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Переход к следующей строке; false — строки кончились или чтение не удалось
+func (r *sheetReader) next() bool {
+	r.head()
+	if len(r.buffer) > 0 {
+		r.current, r.buffer = r.buffer[0], r.buffer[1:]
+		return true
+	}
+	row, ok := r.fetch()
+	if ok {
+		row = r.fillMergedCells(r.read-1, row)
+	}
+	r.current = row
+	return ok
+}
+
+func (r *sheetReader) row() []string {
+	return r.current
+}
+
+// Пропуск n строк, например заголовка таблицы
+func (r *sheetReader) skip(n int) {
+	for i := 0; i < n && r.next(); i++ {
+	}
+}
+
+// Ошибка чтения, из-за которой строки кончились раньше времени
+func (r *sheetReader) err() error {
+	return r.fail
+}
+
+func (r *sheetReader) close() error {
+	return r.source.Close()
+}
+
+func (r *sheetReader) fetch() ([]string, bool) {
+	if r.done {
+		return nil, false
+	}
+	if !r.source.Next() {
+		r.done, r.fail = true, r.source.Error()
+		return nil, false
+	}
+	row, err := r.source.Columns()
+	if err != nil {
+		r.done, r.fail = true, err
+		return nil, false
+	}
+	r.read++
+	r.width = max(r.width, len(row))
+	if r.progress != nil {
+		r.progress(r.read)
+	}
+	return row, true
+}
+
+// Значение объединенной ячейки хранится только в левой верхней клетке.
+// Оно копируется во все клетки диапазона, чтобы заголовок над несколькими
+// колонками и ФИО на несколько строк читались в каждой строке. Строки
+// разворачиваются по порядку, index — номер строки с нуля. Диапазон
+// не расширяет лист: объединение целых строк и колонок обрезается
+// по данным, а пустые строки остаются пустыми
+func (r *sheetReader) fillMergedCells(index int, row []string) []string {
+	active := r.active[:0]
+	for _, m := range r.active {
+		if m.lastRow >= index {
+			active = append(active, m)
+		}
+	}
+	r.active = active
+	for len(r.pending) > 0 && r.pending[0].firstRow <= index {
+		m := r.pending[0]
+		r.pending = r.pending[1:]
+		if m.firstRow == index && m.firstCol < len(row) && row[m.firstCol] != "" {
+			r.active = append(r.active, mergedCell{cellRange: m, value: row[m.firstCol]})
+		}
+	}
+	if len(row) == 0 {
+		return row
+	}
+	for _, m := range r.active {
+		for c := m.firstCol; c <= min(m.lastCol, r.width-1); c++ {
+			for len(row) <= c {
+				row = append(row, "")
+			}
+			row[c] = m.value
+		}
+	}
+	return row
+}
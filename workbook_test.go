@@ -0,0 +1,44 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOpenXLSXSheetLayout(t *testing.T) {
+	wb, err := openWorkbook("Tz-for-tg-bot/Отчет по домашним заданиям.xlsx")
+	if err != nil {
+		t.Fatalf("openWorkbook: %v", err)
+	}
+	defer wb.Close()
+	sh := wb.sheets[0]
+
+	rows, err := sh.open()
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer rows.close()
+	if len(rows.layout.merges) != 7 {
+		t.Errorf("объединенные ячейки: %v, ожидалось 7", rows.layout.merges)
+	}
+	// «Месяц» объединен над пятью колонками
+	if got, want := rows.head()[0][2:7], []string{"Месяц", "Месяц", "Месяц", "Месяц", "Месяц"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("объединенный заголовок: %q, ожидалось %q", got, want)
+	}
+
+	// Для первых строк как есть XML листа заранее не просматривается
+	head, err := sh.openHead()
+	if err != nil {
+		t.Fatalf("openHead: %v", err)
+	}
+	defer head.close()
+	if !reflect.DeepEqual(head.layout, sheetLayout{}) {
+		t.Errorf("openHead прочитал размер и объединения: %+v", head.layout)
+	}
+	if got, want := head.head()[0][2:4], []string{"Месяц", ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("заголовок без объединений: %q, ожидалось %q", got, want)
+	}
+	if sh.blank() {
+		t.Error("лист с данными считается пустым")
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Размер пула по умолчанию и длина очереди каждого обработчика
+const (
+	defaultWorkerCount = 4
+	workerQueueSize    = 100
+)
+
+// Пул обработчиков обновлений. Обновления одного чата всегда попадают в
+// очередь одного и того же обработчика, поэтому внутри чата порядок
+// сохраняется, а разные чаты обрабатываются параллельно
+type updatePool struct {
+	handle func(tgbotapi.Update)
+	// Защищает закрытие очередей от одновременной постановки задач
+	mu     sync.Mutex
+	closed bool
+	queues []chan func()
+	wg     sync.WaitGroup
+}
+
+// Пул, через который бот обрабатывает обновления; nil, пока бот не запущен
+var workers *updatePool
+
+func newUpdatePool(size int, handle func(tgbotapi.Update)) *updatePool {
+	pool := &updatePool{handle: handle, queues: make([]chan func(), size)}
+	for i := range pool.queues {
+		queue := make(chan func(), workerQueueSize)
+		pool.queues[i] = queue
+		pool.wg.Add(1)
+		go func() {
+			defer pool.wg.Done()
+			for job := range queue {
+				job()
+			}
+		}()
+	}
+	return pool
+}
+
+// Постановка обновления в очередь обработчика, закрепленного за чатом
+func (p *updatePool) submit(update tgbotapi.Update) {
+	var chatID int64
+	if chat := update.FromChat(); chat != nil {
+		chatID = chat.ID
+	}
+	p.run(chatID, func() { p.handle(update) })
+}
+
+// Задача в очереди чата после уже поставленных обновлений (например,
+// обработка собранного альбома). Возвращает false, если пул не запущен
+// или уже остановлен, тогда задача не ставится
+func (p *updatePool) run(chatID int64, job func()) bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	index := chatID % int64(len(p.queues))
+	if index < 0 {
+		index = -index
+	}
+	p.queues[index] <- job
+	return true
+}
+
+// Остановка пула: новые обновления больше не принимаются, а уже
+// поставленные в очередь (в том числе файлы в обработке) дорабатываются
+func (p *updatePool) stop() {
+	p.mu.Lock()
+	p.closed = true
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+// Размер пула можно задать переменной окружения worker_count
+func workerCount() int {
+	value, err := strconv.Atoi(os.Getenv("worker_count"))
+	if err != nil || value <= 0 {
+		return defaultWorkerCount
+	}
+	return value
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestUpdatePoolKeepsChatOrder(t *testing.T) {
+	var mu sync.Mutex
+	var order []int
+	pool := newUpdatePool(3, func(update tgbotapi.Update) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, update.UpdateID)
+	})
+	chat := &tgbotapi.Chat{ID: 42}
+	pool.submit(tgbotapi.Update{UpdateID: 1, Message: &tgbotapi.Message{Chat: chat}})
+	// Задача чата (например, альбом) выполняется после уже поставленных обновлений
+	if !pool.run(chat.ID, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, 2)
+	}) {
+		t.Fatal("run: задача не поставлена в очередь работающего пула")
+	}
+	pool.submit(tgbotapi.Update{UpdateID: 3, Message: &tgbotapi.Message{Chat: chat}})
+	pool.stop()
+
+	if want := []int{1, 2, 3}; !slices.Equal(order, want) {
+		t.Errorf("порядок обработки: %v, ожидалось %v", order, want)
+	}
+	if pool.run(chat.ID, func() {}) {
+		t.Error("run после остановки пула поставил задачу")
+	}
+	var none *updatePool
+	if none.run(chat.ID, func() {}) {
+		t.Error("run без пула поставил задачу")
+	}
+}
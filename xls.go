@@ -0,0 +1,599 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	mscfb "github.com/richardlehane/mscfb"
+)
+
+// Чтение старого формата Excel 97-2003 (BIFF8).
+// Книга хранится в потоке "Workbook" OLE-контейнера и состоит из записей
+// вида [тип 2 байта][длина 2 байта][данные]
+
+// Типы используемых записей BIFF8
+const (
+	recFormula    = 0x0006
+	recEOF        = 0x000A
+	recDateMode   = 0x0022
+	recFilePass   = 0x002F
+	recContinue   = 0x003C
+	recBoundSheet = 0x0085
+	recMulRK      = 0x00BD
+	recXF         = 0x00E0
+	recMergeCells = 0x00E5
+	recSST        = 0x00FC
+	recLabelSST   = 0x00FD
+	recNumber     = 0x0203
+	recLabel      = 0x0204
+	recBoolErr    = 0x0205
+	recString     = 0x0207
+	recRK         = 0x027E
+	recFormat     = 0x041E
+	recBOF        = 0x0809
+)
+
+type xlsRecord struct {
+	typ  uint16
+	data []byte
+}
+
+// Описание листа из записи BOUNDSHEET
+type xlsSheetInfo struct {
+	name   string
+	offset int
+	kind   byte
+}
+
+// Состояние разбора книги: общие строки и форматы нужны для ячеек всех листов
+type xlsParser struct {
+	stream   []byte
+	sst      []string
+	formats  map[uint16]string
+	xfFormat []uint16
+	date1904 bool
+}
+
+func readXLS(r io.ReaderAt) (*workbook, error) {
+	doc, err := mscfb.New(r)
+	if err != nil {
+		return nil, fmt.Errorf("повреждённый файл .xls: %w", err)
+	}
+	var stream []byte
+	for entry, err := doc.Next(); err == nil; entry, err = doc.Next() {
+		if entry.Name == "Workbook" {
+			stream, err = io.ReadAll(entry)
+			if err != nil {
+				return nil, fmt.Errorf("не удалось прочитать книгу .xls: %w", err)
+			}
+			break
+		}
+		if entry.Name == "Book" {
+			return nil, &unsupportedFormatError{reason: "формат Excel 5.0/95 не поддерживается, пересохраните файл в .xlsx"}
+		}
+	}
+	if stream == nil {
+		return nil, fmt.Errorf("в файле .xls не найдена книга Excel")
+	}
+
+	p := &xlsParser{stream: stream, formats: make(map[uint16]string)}
+	sheets, err := p.parseGlobals()
+	if err != nil {
+		return nil, err
+	}
+
+	wb := &workbook{}
+	for _, info := range sheets {
+		// Диаграммы и макросы хранятся как отдельные листы, в отчетах они не нужны
+		if info.kind != 0 {
+			continue
+		}
+		// Лист разбирается, только когда до него дошла обработка. Объединенные
+		// ячейки читаются вместе со строками, поэтому отдельного чтения первых
+		// строк не нужно
+		open := func() (*sheetReader, error) {
+			rows, merges, err := p.parseSheet(info.offset)
+			if err != nil {
+				return nil, err
+			}
+			width := 0
+			for _, row := range rows {
+				width = max(width, len(row))
+			}
+			return newSheetReader(&sliceRows{rows: rows}, sheetLayout{rows: len(rows), cols: width, merges: merges}), nil
+		}
+		wb.sheets = append(wb.sheets, sheet{name: info.name, open: open, openHead: open})
+	}
+	return wb, nil
+}
+
+// Чтение записи по смещению; возвращает запись и смещение следующей
+func (p *xlsParser) record(pos int) (xlsRecord, int, error) {
+	if pos+4 > len(p.stream) {
+		return xlsRecord{}, pos, io.ErrUnexpectedEOF
+	}
+	typ := binary.LittleEndian.Uint16(p.stream[pos:])
+	size := int(binary.LittleEndian.Uint16(p.stream[pos+2:]))
+	end := pos + 4 + size
+	if end > len(p.stream) {
+		return xlsRecord{}, pos, io.ErrUnexpectedEOF
+	}
+	return xlsRecord{typ: typ, data: p.stream[pos+4 : end]}, end, nil
+}
+
+// Разбор глобального блока книги: список листов, общие строки, форматы
+func (p *xlsParser) parseGlobals() ([]xlsSheetInfo, error) {
+	rec, pos, err := p.record(0)
+	if err != nil || rec.typ != recBOF {
+		return nil, fmt.Errorf("повреждённый файл .xls: нет заголовка книги")
+	}
+	if len(rec.data) >= 2 && binary.LittleEndian.Uint16(rec.data) != 0x0600 {
+		return nil, &unsupportedFormatError{reason: "поддерживаются только файлы Excel 97-2003 (BIFF8)"}
+	}
+
+	var sheets []xlsSheetInfo
+	var sstChunks [][]byte
+	inSST := false
+	for {
+		rec, pos, err = p.record(pos)
+		if err != nil {
+			return nil, fmt.Errorf("повреждённый файл .xls: %w", err)
+		}
+		if rec.typ == recContinue && inSST {
+			sstChunks = append(sstChunks, rec.data)
+			continue
+		}
+		inSST = false
+
+		switch rec.typ {
+		case recEOF:
+			if sstChunks != nil {
+				p.sst = parseSST(sstChunks)
+			}
+			return sheets, nil
+		case recFilePass:
+			return nil, fmt.Errorf("файл защищён паролем")
+		case recDateMode:
+			p.date1904 = len(rec.data) >= 2 && binary.LittleEndian.Uint16(rec.data) == 1
+		case recFormat:
+			if len(rec.data) >= 5 {
+				code := binary.LittleEndian.Uint16(rec.data)
+				p.formats[code], _ = readXLUnicodeString(rec.data[2:])
+			}
+		case recXF:
+			if len(rec.data) >= 4 {
+				p.xfFormat = append(p.xfFormat, binary.LittleEndian.Uint16(rec.data[2:]))
+			}
+		case recBoundSheet:
+			if len(rec.data) < 8 {
+				continue
+			}
+			info := xlsSheetInfo{
+				offset: int(binary.LittleEndian.Uint32(rec.data)),
+				kind:   rec.data[5],
+			}
+			info.name = readShortXLUnicodeString(rec.data[6:])
+			sheets = append(sheets, info)
+		case recSST:
+			if len(rec.data) >= 8 {
+				sstChunks = [][]byte{rec.data[8:]}
+				inSST = true
+			}
+		}
+	}
+}
+
+// Разбор листа, начинающегося с записи BOF по указанному смещению.
+// Результат повторяет excelize.GetRows: пустые ячейки в конце строк и
+// пустые строки в конце листа отбрасываются. Объединенные ячейки
+// возвращаются отдельно
+func (p *xlsParser) parseSheet(offset int) ([][]string, []cellRange, error) {
+	rec, pos, err := p.record(offset)
+	if err != nil || rec.typ != recBOF {
+		return nil, nil, fmt.Errorf("не найдено начало листа")
+	}
+	var merges []cellRange
+
+	cells := make(map[int]map[int]string)
+	maxRow := -1
+	set := func(row, col int, value string) {
+		if value == "" {
+			return
+		}
+		if cells[row] == nil {
+			cells[row] = make(map[int]string)
+		}
+		cells[row][col] = value
+		if row > maxRow {
+			maxRow = row
+		}
+	}
+
+	// Вложенные блоки BOF/EOF (встроенные диаграммы) пропускаются целиком
+	depth := 1
+	// Строковый результат формулы приходит следующей записью STRING
+	pendingRow, pendingCol := -1, -1
+	for depth > 0 {
+		rec, pos, err = p.record(pos)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch rec.typ {
+		case recBOF:
+			depth++
+			continue
+		case recEOF:
+			depth--
+			continue
+		}
+		if depth > 1 {
+			continue
+		}
+
+		data := rec.data
+		switch rec.typ {
+		case recLabelSST:
+			if len(data) >= 10 {
+				idx := int(binary.LittleEndian.Uint32(data[6:]))
+				if idx < len(p.sst) {
+					set(cellRow(data), cellCol(data), p.sst[idx])
+				}
+			}
+		case recLabel:
+			if len(data) >= 9 {
+				value, _ := readXLUnicodeString(data[6:])
+				set(cellRow(data), cellCol(data), value)
+			}
+		case recNumber:
+			if len(data) >= 14 {
+				value := math.Float64frombits(binary.LittleEndian.Uint64(data[6:]))
+				set(cellRow(data), cellCol(data), p.formatNumber(value, cellXF(data)))
+			}
+		case recRK:
+			if len(data) >= 10 {
+				value := decodeRK(binary.LittleEndian.Uint32(data[6:]))
+				set(cellRow(data), cellCol(data), p.formatNumber(value, cellXF(data)))
+			}
+		case recMulRK:
+			if len(data) < 6 {
+				continue
+			}
+			row, first := cellRow(data), cellCol(data)
+			for i, off := 0, 4; off+6 <= len(data)-2; i, off = i+1, off+6 {
+				xf := binary.LittleEndian.Uint16(data[off:])
+				value := decodeRK(binary.LittleEndian.Uint32(data[off+2:]))
+				set(row, first+i, p.formatNumber(value, xf))
+			}
+		case recBoolErr:
+			if len(data) >= 8 {
+				set(cellRow(data), cellCol(data), formatBoolErr(data[6], data[7] == 1))
+			}
+		case recFormula:
+			if len(data) < 14 {
+				continue
+			}
+			row, col := cellRow(data), cellCol(data)
+			result := data[6:14]
+			if result[6] != 0xFF || result[7] != 0xFF {
+				value := math.Float64frombits(binary.LittleEndian.Uint64(result))
+				set(row, col, p.formatNumber(value, cellXF(data)))
+				continue
+			}
+			switch result[0] {
+			case 0:
+				pendingRow, pendingCol = row, col
+			case 1:
+				set(row, col, formatBoolErr(result[2], false))
+			case 2:
+				set(row, col, formatBoolErr(result[2], true))
+			}
+		case recString:
+			if pendingRow >= 0 {
+				value, _ := readXLUnicodeString(data)
+				set(pendingRow, pendingCol, value)
+				pendingRow, pendingCol = -1, -1
+			}
+		case recMergeCells:
+			// Количество диапазонов и по 8 байт на каждый: строки и колонки от и до
+			if len(data) < 2 {
+				continue
+			}
+			count := int(binary.LittleEndian.Uint16(data))
+			for i, off := 0, 2; i < count && off+8 <= len(data); i, off = i+1, off+8 {
+				merges = append(merges, cellRange{
+					firstRow: int(binary.LittleEndian.Uint16(data[off:])),
+					lastRow:  int(binary.LittleEndian.Uint16(data[off+2:])),
+					firstCol: int(binary.LittleEndian.Uint16(data[off+4:])),
+					lastCol:  int(binary.LittleEndian.Uint16(data[off+6:])),
+				})
+			}
+		}
+	}
+
+	rows := make([][]string, maxRow+1)
+	for r, byCol := range cells {
+		last := -1
+		for c := range byCol {
+			last = max(last, c)
+		}
+		row := make([]string, last+1)
+		for c, value := range byCol {
+			row[c] = value
+		}
+		rows[r] = row
+	}
+	return rows, merges, nil
+}
+
+func cellRow(data []byte) int   { return int(binary.LittleEndian.Uint16(data)) }
+func cellCol(data []byte) int   { return int(binary.LittleEndian.Uint16(data[2:])) }
+func cellXF(data []byte) uint16 { return binary.LittleEndian.Uint16(data[4:]) }
+
+// Распаковка числа в формате RK: целое или усечённый double, возможно умноженные на 100
+func decodeRK(rk uint32) float64 {
+	var value float64
+	if rk&0x02 != 0 {
+		value = float64(int32(rk) >> 2)
+	} else {
+		value = math.Float64frombits(uint64(rk&0xFFFFFFFC) << 32)
+	}
+	if rk&0x01 != 0 {
+		value /= 100
+	}
+	return value
+}
+
+func formatBoolErr(value byte, isError bool) string {
+	if !isError {
+		if value != 0 {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	switch value {
+	case 0x00:
+		return "#NULL!"
+	case 0x07:
+		return "#DIV/0!"
+	case 0x0F:
+		return "#VALUE!"
+	case 0x17:
+		return "#REF!"
+	case 0x1D:
+		return "#NAME?"
+	case 0x24:
+		return "#NUM!"
+	default:
+		return "#N/A"
+	}
+}
+
+// Числа выводятся так же, как их показывает excelize: даты — в виде даты,
+// проценты — со знаком %, остальные — без лишних нулей
+func (p *xlsParser) formatNumber(value float64, xf uint16) string {
+	code := uint16(0)
+	if int(xf) < len(p.xfFormat) {
+		code = p.xfFormat[xf]
+	}
+	format, custom := p.formats[code]
+	switch {
+	case isDateFormat(code, format, custom):
+		return p.formatDate(value)
+	case code == 9 || code == 10 || (custom && strings.Contains(stripFormatLiterals(format), "%")):
+		return formatGeneral(value*100) + "%"
+	default:
+		return formatGeneral(value)
+	}
+}
+
+// Округление до 15 значащих цифр убирает хвосты вроде 0.30000000000000004
+func formatGeneral(value float64) string {
+	rounded, err := strconv.ParseFloat(strconv.FormatFloat(value, 'g', 15, 64), 64)
+	if err != nil {
+		rounded = value
+	}
+	return strconv.FormatFloat(rounded, 'f', -1, 64)
+}
+
+func (p *xlsParser) formatDate(value float64) string {
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	if p.date1904 {
+		epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	days := math.Floor(value)
+	seconds := math.Round((value - days) * 86400)
+	date := epoch.AddDate(0, 0, int(days)).Add(time.Duration(seconds) * time.Second)
+	switch {
+	case value < 1:
+		return date.Format("15:04:05")
+	case seconds == 0:
+		return date.Format("2006-01-02")
+	default:
+		return date.Format("2006-01-02 15:04:05")
+	}
+}
+
+// Встроенные форматы дат Excel и пользовательские форматы с датой/временем
+func isDateFormat(code uint16, format string, custom bool) bool {
+	if !custom {
+		return (code >= 14 && code <= 22) || (code >= 45 && code <= 47)
+	}
+	stripped := strings.ToLower(stripFormatLiterals(format))
+	if stripped == "general" {
+		return false
+	}
+	return strings.ContainsAny(stripped, "dmyhs")
+}
+
+// Удаление из кода формата текста в кавычках, экранированных символов и [цветов]
+func stripFormatLiterals(format string) string {
+	var b strings.Builder
+	inQuotes, inBrackets, escaped := false, false, false
+	for _, r := range format {
+		switch {
+		case escaped:
+			escaped = false
+		case inQuotes:
+			inQuotes = r != '"'
+		case inBrackets:
+			inBrackets = r != ']'
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			inQuotes = true
+		case r == '[':
+			inBrackets = true
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Строка с 16-битной длиной (XLUnicodeString); возвращает строку и число прочитанных байт
+func readXLUnicodeString(data []byte) (string, int) {
+	if len(data) < 3 {
+		return "", len(data)
+	}
+	count := int(binary.LittleEndian.Uint16(data))
+	value, n := decodeChars(data[3:], count, data[2]&0x01 != 0)
+	return value, 3 + n
+}
+
+// Строка с 8-битной длиной (ShortXLUnicodeString), используется в именах листов
+func readShortXLUnicodeString(data []byte) string {
+	if len(data) < 2 {
+		return ""
+	}
+	value, _ := decodeChars(data[2:], int(data[0]), data[1]&0x01 != 0)
+	return value
+}
+
+// Символы хранятся либо в UTF-16LE, либо «сжатыми» — по одному младшему байту
+func decodeChars(data []byte, count int, highByte bool) (string, int) {
+	if highByte {
+		count = min(count, len(data)/2)
+		units := make([]uint16, count)
+		for i := range units {
+			units[i] = binary.LittleEndian.Uint16(data[i*2:])
+		}
+		return string(utf16.Decode(units)), count * 2
+	}
+	count = min(count, len(data))
+	runes := make([]rune, count)
+	for i := range runes {
+		runes[i] = rune(data[i])
+	}
+	return string(runes), count
+}
+
+// Чтение таблицы общих строк (SST). Таблица может быть разбита на несколько
+// записей CONTINUE, причём строка может оборваться посередине: тогда в начале
+// следующего куска повторяется байт флагов с признаком кодировки
+type sstReader struct {
+	chunks [][]byte
+	chunk  int
+	pos    int
+}
+
+func parseSST(chunks [][]byte) []string {
+	r := &sstReader{chunks: chunks}
+	var result []string
+	for !r.done() {
+		value, ok := r.readString()
+		if !ok {
+			break
+		}
+		result = append(result, value)
+	}
+	return result
+}
+
+func (r *sstReader) done() bool {
+	for r.chunk < len(r.chunks) && r.pos >= len(r.chunks[r.chunk]) {
+		r.chunk++
+		r.pos = 0
+	}
+	return r.chunk >= len(r.chunks)
+}
+
+func (r *sstReader) bytes(n int) ([]byte, bool) {
+	out := make([]byte, 0, n)
+	for len(out) < n {
+		if r.done() {
+			return nil, false
+		}
+		cur := r.chunks[r.chunk]
+		take := min(n-len(out), len(cur)-r.pos)
+		out = append(out, cur[r.pos:r.pos+take]...)
+		r.pos += take
+	}
+	return out, true
+}
+
+func (r *sstReader) readString() (string, bool) {
+	head, ok := r.bytes(3)
+	if !ok {
+		return "", false
+	}
+	count := int(binary.LittleEndian.Uint16(head))
+	flags := head[2]
+	runs, extSize := 0, 0
+	if flags&0x08 != 0 {
+		b, ok := r.bytes(2)
+		if !ok {
+			return "", false
+		}
+		runs = int(binary.LittleEndian.Uint16(b))
+	}
+	if flags&0x04 != 0 {
+		b, ok := r.bytes(4)
+		if !ok {
+			return "", false
+		}
+		extSize = int(binary.LittleEndian.Uint32(b))
+	}
+
+	highByte := flags&0x01 != 0
+	var sb strings.Builder
+	for count > 0 {
+		if r.chunk >= len(r.chunks) {
+			return "", false
+		}
+		cur := r.chunks[r.chunk]
+		if r.pos >= len(cur) {
+			// Продолжение строки в следующей записи начинается с нового байта флагов
+			r.chunk++
+			r.pos = 0
+			if r.chunk >= len(r.chunks) || len(r.chunks[r.chunk]) == 0 {
+				return "", false
+			}
+			highByte = r.chunks[r.chunk][0]&0x01 != 0
+			r.pos = 1
+			continue
+		}
+		size := 1
+		if highByte {
+			size = 2
+		}
+		available := min(count, (len(cur)-r.pos)/size)
+		if available == 0 {
+			return "", false
+		}
+		value, n := decodeChars(cur[r.pos:], available, highByte)
+		sb.WriteString(value)
+		r.pos += n
+		count -= available
+	}
+
+	if _, ok := r.bytes(runs*4 + extSize); !ok {
+		return "", false
+	}
+	return sb.String(), true
+}
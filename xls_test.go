@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+)
+
+// Запись BIFF8: тип, длина и данные
+func biffRecord(typ uint16, data []byte) []byte {
+	out := binary.LittleEndian.AppendUint16(nil, typ)
+	out = binary.LittleEndian.AppendUint16(out, uint16(len(data)))
+	return append(out, data...)
+}
+
+// Начало ячейки: строка, колонка и индекс XF
+func biffCell(row, col int) []byte {
+	out := binary.LittleEndian.AppendUint16(nil, uint16(row))
+	out = binary.LittleEndian.AppendUint16(out, uint16(col))
+	return binary.LittleEndian.AppendUint16(out, 0)
+}
+
+// Символы строки в UTF-16LE
+func utf16Bytes(s string) []byte {
+	var out []byte
+	for _, unit := range utf16.Encode([]rune(s)) {
+		out = binary.LittleEndian.AppendUint16(out, unit)
+	}
+	return out
+}
+
+func TestOpenWorkbookXLS(t *testing.T) {
+	wb, err := openWorkbook("Tz-for-tg-bot/Расписание групп.xls")
+	if err != nil {
+		t.Fatalf("openWorkbook: %v", err)
+	}
+	defer wb.Close()
+	if len(wb.sheets) != 1 || wb.sheets[0].name != "Worksheet" {
+		t.Fatalf("листы книги: %+v", wb.sheets)
+	}
+
+	rows, err := wb.sheets[0].open()
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer rows.close()
+	if len(rows.layout.merges) != 0 {
+		t.Errorf("объединенные ячейки: %v, в файле их нет", rows.layout.merges)
+	}
+	head := rows.head()
+	if len(head) < 3 {
+		t.Fatalf("прочитано строк: %d", len(head))
+	}
+
+	wantHeader := []string{"Группа", "Пара", "Время", "Понедельник. 15.12.2025", "Время", "Вторник. 16.12.2025"}
+	if got := head[0][:len(wantHeader)]; !reflect.DeepEqual(got, wantHeader) {
+		t.Errorf("заголовок: %q, ожидалось %q", got, wantHeader)
+	}
+	if len(head[0]) != 16 {
+		t.Errorf("колонок в заголовке: %d, ожидалось 16", len(head[0]))
+	}
+	// Номер пары хранится числом, группа и предмет — общими строками (SST)
+	cells := []struct {
+		row, col int
+		want     string
+	}{
+		{1, 0, "9/3-РПО-23/2"},
+		{1, 1, "0"},
+		{2, 1, "1"},
+		{2, 6, "09:00-10:30"},
+		{2, 7, "Предмет: Управление программными проектами РПО\nГруппа: 9/3-РПО-23/2\nПрепод.: Ярцев Дмитрий Иванович"},
+	}
+	for _, c := range cells {
+		if c.col >= len(head[c.row]) || head[c.row][c.col] != c.want {
+			t.Errorf("ячейка (%d, %d): %q, ожидалось %q", c.row, c.col, head[c.row], c.want)
+		}
+	}
+}
+
+func TestParseSheetMergedCells(t *testing.T) {
+	label := biffCell(0, 0)
+	label = binary.LittleEndian.AppendUint16(label, uint16(len([]rune("ФИО"))))
+	label = append(label, 0x01)
+	label = append(label, utf16Bytes("ФИО")...)
+
+	number := binary.LittleEndian.AppendUint64(biffCell(1, 0), math.Float64bits(42.5))
+	// RK: целое 7, сдвинутое на два бита, с признаком целого числа
+	rk := binary.LittleEndian.AppendUint32(biffCell(2, 1), 7<<2|0x02)
+
+	// Заголовок над двумя колонками (A1:B1) и значение на две строки (A2:A3)
+	merges := binary.LittleEndian.AppendUint16(nil, 2)
+	for _, r := range [][4]uint16{{0, 0, 0, 1}, {1, 2, 0, 0}} {
+		for _, v := range r {
+			merges = binary.LittleEndian.AppendUint16(merges, v)
+		}
+	}
+
+	bof := binary.LittleEndian.AppendUint16(nil, 0x0600)
+	bof = append(bof, make([]byte, 14)...)
+	var stream []byte
+	stream = append(stream, biffRecord(recBOF, bof)...)
+	stream = append(stream, biffRecord(recLabel, label)...)
+	stream = append(stream, biffRecord(recNumber, number)...)
+	stream = append(stream, biffRecord(recRK, rk)...)
+	stream = append(stream, biffRecord(recMergeCells, merges)...)
+	stream = append(stream, biffRecord(recEOF, nil)...)
+
+	p := &xlsParser{stream: stream, formats: make(map[uint16]string)}
+	rows, ranges, err := p.parseSheet(0)
+	if err != nil {
+		t.Fatalf("parseSheet: %v", err)
+	}
+	wantRows := [][]string{{"ФИО"}, {"42.5"}, {"", "7"}}
+	if !reflect.DeepEqual(rows, wantRows) {
+		t.Errorf("строки: %q, ожидалось %q", rows, wantRows)
+	}
+	wantRanges := []cellRange{
+		{firstRow: 0, lastRow: 0, firstCol: 0, lastCol: 1},
+		{firstRow: 1, lastRow: 2, firstCol: 0, lastCol: 0},
+	}
+	if !reflect.DeepEqual(ranges, wantRanges) {
+		t.Errorf("объединения: %+v, ожидалось %+v", ranges, wantRanges)
+	}
+
+	reader := newSheetReader(&sliceRows{rows: rows}, sheetLayout{rows: len(rows), cols: 2, merges: ranges})
+	var filled [][]string
+	for reader.next() {
+		filled = append(filled, reader.row())
+	}
+	wantFilled := [][]string{{"ФИО", "ФИО"}, {"42.5"}, {"42.5", "7"}}
+	if !reflect.DeepEqual(filled, wantFilled) {
+		t.Errorf("развернутые строки: %q, ожидалось %q", filled, wantFilled)
+	}
+}
+
+func TestParseSST(t *testing.T) {
+	// Строка «сжата» (по байту на символ), если в ней только латиница
+	compressed := func(s string) []byte {
+		out := binary.LittleEndian.AppendUint16(nil, uint16(len(s)))
+		return append(append(out, 0x00), s...)
+	}
+	wide := func(s string) []byte {
+		out := binary.LittleEndian.AppendUint16(nil, uint16(len(utf16.Encode([]rune(s)))))
+		return append(append(out, 0x01), utf16Bytes(s)...)
+	}
+
+	t.Run("одна запись", func(t *testing.T) {
+		chunk := append(compressed("Group"), wide("Группа")...)
+		got := parseSST([][]byte{chunk})
+		if want := []string{"Group", "Группа"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("parseSST: %q, ожидалось %q", got, want)
+		}
+	})
+
+	t.Run("строка разбита записью CONTINUE", func(t *testing.T) {
+		// «Иванов» обрывается после трех символов; продолжение начинается
+		// с байта флагов, а строка после нее снова идет целиком
+		first := append(compressed("ok"), wide("Иванов")[:3+3*2]...)
+		second := append([]byte{0x01}, utf16Bytes("нов")...)
+		second = append(second, wide("Петров")...)
+		got := parseSST([][]byte{first, second})
+		if want := []string{"ok", "Иванов", "Петров"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("parseSST: %q, ожидалось %q", got, want)
+		}
+	})
+
+	t.Run("продолжение в другой кодировке", func(t *testing.T) {
+		// Начало строки в UTF-16, продолжение «сжато»: признак берется из байта флагов
+		first := wide("Тема: abc")[:3+6*2]
+		second := append([]byte{0x00}, "abc"...)
+		got := parseSST([][]byte{first, second})
+		if want := []string{"Тема: abc"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("parseSST: %q, ожидалось %q", got, want)
+		}
+	})
+
+	t.Run("форматирование и расширенные данные пропускаются", func(t *testing.T) {
+		// Флаги 0x08 (runs) и 0x04 (ext): после символов идут 4 байта на run и ext байт
+		rich := binary.LittleEndian.AppendUint16(nil, 3)
+		rich = append(rich, 0x0C)
+		rich = binary.LittleEndian.AppendUint16(rich, 1)
+		rich = binary.LittleEndian.AppendUint32(rich, 2)
+		rich = append(rich, "abc"...)
+		rich = append(rich, 0, 0, 1, 0, 0xAA, 0xBB)
+		chunk := append(rich, compressed("next")...)
+		got := parseSST([][]byte{chunk})
+		if want := []string{"abc", "next"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("parseSST: %q, ожидалось %q", got, want)
+		}
+	})
+
+	t.Run("обрыв таблицы", func(t *testing.T) {
+		chunk := append(compressed("ok"), wide("Иванов")[:5]...)
+		got := parseSST([][]byte{chunk})
+		if want := []string{"ok"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("parseSST: %q, ожидалось %q", got, want)
+		}
+	})
+}